@@ -0,0 +1,114 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Aggregation over the values of repeated AVPs, on the root indexer or any path scoped one:
+//
+//	total := Aggregates[uint32](ai, 0, 420)                                     // CC-Time: Count, Sum, Min, Max
+//	first := AggregateTime(ai.FromGroup(10415, 874), 10415, 2043).Earliest  // Time-First-Usage
+//
+// Values are read like GetAll[T] reads them, so AVPs that can't be decoded as T are left out.
+
+// ErrSumOverflow is returned by Sum when the total doesn't fit the result type.
+var ErrSumOverflow = errors.New("sum overflow")
+
+// Number is the set of AVP value types that can be aggregated; named types such as `type Plan uint32` are read as
+// their underlying type unless registered themselves.
+type Number interface {
+	~uint32 | ~uint64 | ~int32 | ~int64 | ~float32 | ~float64
+}
+
+// Aggregate of the values of all matching AVPs.  Min, Max and Sum are zero when Count is 0.
+type Aggregate[T Number] struct {
+	Count    int
+	Sum      T
+	Min      T
+	Max      T
+	Overflow bool // Sum didn't fit in T and is not meaningful; Average still is
+	fsum     float64
+}
+
+// mean of the values, computed in float64; 0 if there are none
+func (ag Aggregate[T]) Average() float64 {
+	if ag.Count == 0 {
+		return 0
+	}
+	return ag.fsum / float64(ag.Count)
+}
+
+func (ag *Aggregate[T]) add(v T) {
+	if ag.Count == 0 || v < ag.Min {
+		ag.Min = v
+	}
+	if ag.Count == 0 || v > ag.Max {
+		ag.Max = v
+	}
+	ag.Count++
+	ag.fsum += float64(v)
+	var ok bool
+	if ag.Sum, ok = addChecked(ag.Sum, v); !ok {
+		ag.Overflow = true
+	}
+}
+
+// a+b, and false if the result overflowed T
+func addChecked[T Number](a, b T) (T, bool) {
+	s := a + b
+	if b > 0 && s < a || b < 0 && s > a {
+		return s, false // integer wrap around
+	}
+	if s != 0 && s+s == s {
+		return s, false // float Inf; for integer types only 0 doubles to itself
+	}
+	return s, true
+}
+
+// aggregate the values of type T of all matching AVPs with given id
+func Aggregates[T Number](ix Indexer, vendorId, attrId uint32) Aggregate[T] {
+	var ag Aggregate[T]
+	for _, v := range GetAll[T](ix, vendorId, attrId) {
+		ag.add(v)
+	}
+	return ag
+}
+
+// add up values of type T of all matching AVPs with given id; ErrSumOverflow if the total doesn't fit in T
+func Sum[T Number](ix Indexer, vendorId, attrId uint32) (T, error) {
+	ag := Aggregates[T](ix, vendorId, attrId)
+	if ag.Overflow {
+		var zero T
+		return zero, fmt.Errorf("%s: %w", Path{leaf: scopedPath(ix, vendorId, attrId)}, ErrSumOverflow)
+	}
+	return ag.Sum, nil
+}
+
+func scopedPath(ix Indexer, vendorId, attrId uint32) *pathElement {
+	_, parent := ix.scope()
+	return leafPath(parent, vendorId, attrId)
+}
+
+// Aggregate of the time.Time values of all matching AVPs.  Earliest and Latest are zero when Count is 0.
+type TimeAggregate struct {
+	Count    int
+	Earliest time.Time
+	Latest   time.Time
+}
+
+// aggregate the time.Time values of all matching AVPs with given id
+func AggregateTime(ix Indexer, vendorId, attrId uint32) TimeAggregate {
+	var ag TimeAggregate
+	for _, t := range GetAll[time.Time](ix, vendorId, attrId) {
+		if ag.Count == 0 || t.Before(ag.Earliest) {
+			ag.Earliest = t
+		}
+		if ag.Count == 0 || t.After(ag.Latest) {
+			ag.Latest = t
+		}
+		ag.Count++
+	}
+	return ag
+}
@@ -0,0 +1,101 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"math"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	ag := Aggregates[uint64](ai.FromGroup(10415, 2040), 0, 364)
+	a.Equal(t, ag.Count, 2)
+	a.Equal(t, ag.Sum, uint64(3208+26694))
+	a.Equal(t, ag.Min, uint64(3208))
+	a.Equal(t, ag.Max, uint64(26694))
+	a.Equal(t, ag.Average(), float64(3208+26694)/2)
+	a.Assert(t, !ag.Overflow)
+
+	ag32 := Aggregates[uint32](ai, 10415, 2045)
+	a.Equal(t, ag32.Sum, uint32(841))
+	a.Equal(t, ag32.Min, uint32(241))
+	a.Equal(t, ag32.Max, uint32(600))
+
+	agi := Aggregates[int32](ai, Wildcard, 2037)
+	a.Equal(t, agi.Count, 3)
+	a.Equal(t, agi.Sum, int32(3))
+
+	// named types are read as their underlying type
+	type plan uint32
+	agp := Aggregates[plan](ai.FromGroup(10415, 2040), 0, 432)
+	a.Equal(t, agp.Count, 2)
+	a.Equal(t, agp.Sum, plan(4001))
+	_, ok := Lookup[plan](ai.FromGroup(10415, 2040), 0, 432)
+	a.Assert(t, ok)
+	a.DeepEqual(t, GetAll[plan](ai, 0, 432), []plan{0, 4001})
+
+	// type mismatches and missing AVPs are left out
+	a.Equal(t, Aggregates[uint64](ai, 10415, 2045).Count, 0)
+	a.Equal(t, Aggregates[float64](ai, 0, 999).Average(), float64(0))
+
+	sum, err := Sum[uint64](ai, 0, 363)
+	a.NilError(t, err)
+	a.Equal(t, sum, uint64(500+13492))
+
+	a.Equal(t, ai.AccumulateUint64(10415, 2045), uint64(0))
+}
+
+type testBigCounter uint32
+
+func TestSumOverflow(t *testing.T) {
+	RegisterType(func(avp *AVP) (testBigCounter, error) {
+		return testBigCounter(math.MaxUint32 - 10), nil
+	})
+	ai := NewAvpIndexer(d)
+
+	ag := Aggregates[testBigCounter](ai, 0, 364)
+	a.Equal(t, ag.Count, 2)
+	a.Assert(t, ag.Overflow)
+	a.Equal(t, ag.Average(), float64(math.MaxUint32-10))
+
+	_, err := Sum[testBigCounter](ai.FromGroup(10415, 2040), 0, 364)
+	a.Assert(t, errors.Is(err, ErrSumOverflow))
+	a.ErrorContains(t, err, "10415/2040/0/364")
+
+	_, err = Sum[testBigCounter](ai.FromGroup(10415, 874), 10415, 2)
+	a.NilError(t, err)
+}
+
+func TestAddChecked(t *testing.T) {
+	_, ok := addChecked[int32](math.MaxInt32, 1)
+	a.Assert(t, !ok)
+	_, ok = addChecked[int64](math.MinInt64, -1)
+	a.Assert(t, !ok)
+	_, ok = addChecked[int64](math.MinInt64, 1)
+	a.Assert(t, ok)
+	_, ok = addChecked[uint64](math.MaxUint64, 1)
+	a.Assert(t, !ok)
+	_, ok = addChecked[float32](math.MaxFloat32, math.MaxFloat32)
+	a.Assert(t, !ok)
+	_, ok = addChecked[float64](-1.5, 1.5)
+	a.Assert(t, ok)
+	s, ok := addChecked[uint32](0, 0)
+	a.Assert(t, ok)
+	a.Equal(t, s, uint32(0))
+}
+
+func TestAggregateTime(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	ag := AggregateTime(ai.FromGroup(10415, 874), 10415, 2043)
+	a.Equal(t, ag.Count, 0)
+	a.Assert(t, ag.Earliest.IsZero())
+
+	ag = AggregateTime(ai, 10415, 2044)
+	a.Equal(t, ag.Count, 2)
+	times := ai.GetAllTime(10415, 2044)
+	a.Equal(t, ag.Earliest, times[0])
+	a.Equal(t, ag.Latest, times[1])
+}
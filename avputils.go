@@ -3,7 +3,7 @@ package avpindexer
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/google/gopacket/layers"
+	"github.com/rjm2718/avpindexer/dictionary"
 	"net"
 	"time"
 )
@@ -18,7 +18,9 @@ import (
 //  ai.FromGroup(vendorId,attrId).AccumulateUint64(vendorId,attrId)
 //  ai.FromGroup(vendorId,attrId).VisitAvp(vendorId, attrId, f)
 type AvpIndexer struct {
-	index map[avpId][]pathElementLeafNode
+	ix   *avpIndex              // nil for the zero value, which matches nothing
+	gen  uint32                 // ix.gen it was made for; after ix is reset for another message, it matches nothing
+	dict *dictionary.Dictionary // set by WithDictionary; nil means the message's, see dictionary()
 }
 
 type avpId struct {
@@ -30,63 +32,183 @@ type avpId struct {
 type pathElement struct {
 	avpId
 	parent *pathElement
+	kind   pathElementKind
+	node   *pathElement // for kindNode, the indexed element of the group instance
+	pos    int          // for indexed elements, position of the AVP in avpIndex.nodes
+	end    int          // and of the end of its sub-AVPs
+}
+
+// query paths may contain marker elements besides AVP ids; indexed paths never do.
+type pathElementKind uint8
+
+const (
+	kindAvp     pathElementKind = iota
+	kindRoot                    // message root: the element below it must be a top level AVP
+	kindDescend                 // zero or more groups between the element below it and its parent
+	kindNode                    // one particular grouped AVP instance in the message, see EachGroup
+)
+
+// marks a query path as anchored at the message root
+var rootPathElement = &pathElement{kind: kindRoot}
+
+// return path p followed by a descendant marker; repeated markers are collapsed into one
+func descend(p *pathElement) *pathElement {
+	if p != nil && p.kind == kindDescend {
+		return p
+	}
+	return &pathElement{kind: kindDescend, parent: p}
 }
 
 type pathElementLeafNode struct {
 	pathElement
-	avp *layers.AVP
+	avp   *AVP
+	group *pathElement // parent element of this AVP's sub-AVPs, identifies this instance of a grouped AVP
 }
 
-// with this AvpIndexer instance, retrieval operations start at given path
+// with this AvpIndexer instance, retrieval operations start at given path.  A group added with FromGroup must be
+// the direct child of the group before it, see Descendants() to relax that.
 type avpIndexerWithPath struct {
 	AvpIndexer
 	parent *pathElement
 }
 
-// Create a new instance of an AvpIndexer for the diameter message.
-func NewAvpIndexer(d *layers.Diameter) AvpIndexer {
-
-	// Build simple index over all AVPs; map built by traversing all AVPs in the message.
-	// don't over-think indexing yet, probably a lazily built prefix cache (trie) will
-	// be better, but so far this is fast enough given how small most diameter messages are.
-	// note: different AVPs with the same avpId can be at separate nodes, but stored in same
-	// list in this index; the retrieval functions account for that during match.
-	ai := AvpIndexer{
-		index: make(map[avpId][]pathElementLeafNode, 1),
-	}
-	for _, avp := range d.AVPs {
-		ai.buildPathElementsIndex(nil, avp)
+// Indexer is the retrieval interface shared by AvpIndexer and the path scoped indexers returned by FromGroup,
+// AtRoot etc, so code can work on either.
+type Indexer interface {
+	GetUint32(vendorId, attrId uint32) uint32
+	GetEnumerated(vendorId, attrId uint32) uint32
+	GetUint64(vendorId, attrId uint32) uint64
+	GetInt32(vendorId, attrId uint32) int32
+	GetInt64(vendorId, attrId uint32) int64
+	GetFloat32(vendorId, attrId uint32) float32
+	GetFloat64(vendorId, attrId uint32) float64
+	GetTime(vendorId, attrId uint32) time.Time
+	GetUTF8String(vendorId, attrId uint32) string
+	GetIPAddress(vendorId, attrId uint32) net.IP
+
+	LookupUint32(vendorId, attrId uint32) (uint32, bool)
+	LookupEnumerated(vendorId, attrId uint32) (uint32, bool)
+	LookupUint64(vendorId, attrId uint32) (uint64, bool)
+	LookupInt32(vendorId, attrId uint32) (int32, bool)
+	LookupInt64(vendorId, attrId uint32) (int64, bool)
+	LookupFloat32(vendorId, attrId uint32) (float32, bool)
+	LookupFloat64(vendorId, attrId uint32) (float64, bool)
+	LookupTime(vendorId, attrId uint32) (time.Time, bool)
+	LookupUTF8String(vendorId, attrId uint32) (string, bool)
+	LookupIPAddress(vendorId, attrId uint32) (net.IP, bool)
+
+	GetUint32E(vendorId, attrId uint32) (uint32, error)
+	GetEnumeratedE(vendorId, attrId uint32) (uint32, error)
+	GetUint64E(vendorId, attrId uint32) (uint64, error)
+	GetInt32E(vendorId, attrId uint32) (int32, error)
+	GetInt64E(vendorId, attrId uint32) (int64, error)
+	GetFloat32E(vendorId, attrId uint32) (float32, error)
+	GetFloat64E(vendorId, attrId uint32) (float64, error)
+	GetTimeE(vendorId, attrId uint32) (time.Time, error)
+	GetUTF8StringE(vendorId, attrId uint32) (string, error)
+	GetIPAddressE(vendorId, attrId uint32) (net.IP, error)
+
+	GetEnum(vendorId, attrId uint32) Enum
+	GetEnumeratedName(vendorId, attrId uint32) string
+	GetValue(vendorId, attrId uint32) interface{}
+	GetValueE(vendorId, attrId uint32) (interface{}, error)
+
+	GetUint32ByName(name string) (uint32, error)
+	GetEnumeratedByName(name string) (uint32, error)
+	GetUint64ByName(name string) (uint64, error)
+	GetInt32ByName(name string) (int32, error)
+	GetInt64ByName(name string) (int64, error)
+	GetFloat32ByName(name string) (float32, error)
+	GetFloat64ByName(name string) (float64, error)
+	GetTimeByName(name string) (time.Time, error)
+	GetUTF8StringByName(name string) (string, error)
+	GetIPAddressByName(name string) (net.IP, error)
+
+	GetAllUint32(vendorId, attrId uint32) []uint32
+	GetAllEnumerated(vendorId, attrId uint32) []uint32
+	GetAllUint64(vendorId, attrId uint32) []uint64
+	GetAllInt32(vendorId, attrId uint32) []int32
+	GetAllInt64(vendorId, attrId uint32) []int64
+	GetAllFloat32(vendorId, attrId uint32) []float32
+	GetAllFloat64(vendorId, attrId uint32) []float64
+	GetAllTime(vendorId, attrId uint32) []time.Time
+	GetAllUTF8String(vendorId, attrId uint32) []string
+	GetAllIPAddress(vendorId, attrId uint32) []net.IP
+
+	Count(vendorId, attrId uint32) int
+	Exists(vendorId, attrId uint32) bool
+	First(vendorId, attrId uint32) *AVP
+	Last(vendorId, attrId uint32) *AVP
+	Nth(vendorId, attrId uint32, i int) *AVP
+
+	EachGroup(vendorId, attrId uint32, f func(sub Indexer)) int
+	Groups(vendorId, attrId uint32) []Indexer
+
+	VisitAvp(vendorId, attrId uint32, f func(avp *AVP)) int
+	AccumulateUint64(vendorId, attrId uint32) uint64
+	FromGroup(vendorId, attrId uint32) avpIndexerWithPath
+	Query(path string) avpQuery
+	QueryPath(p Path) avpQuery
+	QueryName(path string) avpQuery
+
+	// base indexer and the path retrieval operations start at (nil for the whole message)
+	scope() (AvpIndexer, *pathElement)
+}
+
+var _ Indexer = AvpIndexer{}
+var _ Indexer = avpIndexerWithPath{}
+
+func (ai AvpIndexer) scope() (AvpIndexer, *pathElement) {
+	return ai, nil
+}
+
+func (aip avpIndexerWithPath) scope() (AvpIndexer, *pathElement) {
+	return aip.AvpIndexer, aip.parent
+}
+
+// base indexer and path of the AVPs with given id, for the typed getters
+func (ai AvpIndexer) at(vendorId, attrId uint32) (AvpIndexer, *pathElement) {
+	return ai, leafPath(nil, vendorId, attrId)
+}
+
+func (aip avpIndexerWithPath) at(vendorId, attrId uint32) (AvpIndexer, *pathElement) {
+	return aip.AvpIndexer, leafPath(aip.parent, vendorId, attrId)
+}
+
+// Create a new instance of an AvpIndexer for the diameter message.  The AVPs are recorded here, the path index is
+// built by the first lookup (see BuildIndex); copies of the indexer, such as those made by FromGroup or
+// WithDictionary, share both.  Names and enumerated values are looked up in the dictionary d was decoded with, unless
+// replaced by WithDictionary.
+func NewAvpIndexer(d *Diameter) AvpIndexer {
+	ix := newAvpIndex(d.AVPs)
+	ix.dict = d.dict
+	return AvpIndexer{ix: ix, gen: ix.gen}
+}
+
+// Build the path index now rather than on the first lookup, e.g. to keep that cost out of a latency sensitive path.
+// Returns ai for chaining.
+func (ai AvpIndexer) BuildIndex() AvpIndexer {
+	if ai.ix != nil && !ai.ix.linear() {
+		ai.ix.pathTrie()
 	}
 	return ai
 }
 
-func (ai *AvpIndexer) buildPathElementsIndex(parent *pathElement, avp *layers.AVP) {
-
-	aid := avpId{
-		vendorId: avp.VendorCode,
-		attrId:   avp.AttributeCode,
-	}
-
-	pe := pathElement{
-		avpId:  aid,
-		parent: parent,
-	}
-
-	if len(avp.Grouped) > 0 {
-		for _, avp2 := range avp.Grouped {
-			ai.buildPathElementsIndex(&pe, avp2)
-		}
-	}
+const wildcardValue = 1<<32 - 1
 
-	p := pathElementLeafNode{
-		pathElement: pe,
-		avp:         avp,
-	}
-	ai.index[aid] = append(ai.index[aid], p)
+// Wildcard may be given as vendorId and/or attrId anywhere in a path to match any value, e.g.
+// FromGroup(Wildcard, 873) or GetUint32(Wildcard, 432).
+const Wildcard uint32 = wildcardValue
 
+func (p avpId) hasWildcard() bool {
+	return p.vendorId == wildcardValue || p.attrId == wildcardValue
 }
 
-const wildcardValue = 1<<32 - 1
+// true if id matches this (possibly wildcard) id; not symmetrical, wildcards are only honored on p.
+func (p avpId) matchesId(id avpId) bool {
+	return (p.vendorId == wildcardValue || p.vendorId == id.vendorId) &&
+		(p.attrId == wildcardValue || p.attrId == id.attrId)
+}
 
 func (p avpId) skey() string {
 	var s string
@@ -104,179 +226,172 @@ func (p avpId) skey() string {
 }
 
 func (p pathElement) skey2() string {
-	s := p.skey()
+	var s string
+	switch p.kind {
+	case kindAvp:
+		s = p.skey()
+	case kindDescend:
+		s = "**"
+	case kindNode:
+		return p.node.skey2() + "."
+	}
 	if p.parent != nil {
 		s += "." + p.parent.skey2()
 	}
 	return s
 }
 
+// Typed getters use the first matching AVP of the type asked for; matches of other types, e.g. through a Wildcard
+// or Descendants, are skipped.
+
 // retrieve first matching uint32 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetUint32(vendorId, attrId uint32) uint32 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterUnsigned32{}).(*layers.DiameterUnsigned32).Get()
+	return getValue[*DiameterUnsigned32, uint32](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching uint32 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetUint32(vendorId, attrId uint32) uint32 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterUnsigned32{}).(*layers.DiameterUnsigned32).Get()
+	return getValue[*DiameterUnsigned32, uint32](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching enumerated (uint32) value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetEnumerated(vendorId, attrId uint32) uint32 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterEnumerated{}).(*layers.DiameterEnumerated).Get()
+	return getValue[*DiameterEnumerated, uint32](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching enumerated (uint32) value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetEnumerated(vendorId, attrId uint32) uint32 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterEnumerated{}).(*layers.DiameterEnumerated).Get()
+	return getValue[*DiameterEnumerated, uint32](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching uint64 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetUint64(vendorId, attrId uint32) uint64 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterUnsigned64{}).(*layers.DiameterUnsigned64).Get()
+	return getValue[*DiameterUnsigned64, uint64](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching uint64 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetUint64(vendorId, attrId uint32) uint64 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterUnsigned64{}).(*layers.DiameterUnsigned64).Get()
+	return getValue[*DiameterUnsigned64, uint64](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching int32 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetInt32(vendorId, attrId uint32) int32 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterInteger32{}).(*layers.DiameterInteger32).Get()
+	return getValue[*DiameterInteger32, int32](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching int32 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetInt32(vendorId, attrId uint32) int32 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterInteger32{}).(*layers.DiameterInteger32).Get()
+	return getValue[*DiameterInteger32, int32](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching int64 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetInt64(vendorId, attrId uint32) int64 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterInteger64{}).(*layers.DiameterInteger64).Get()
+	return getValue[*DiameterInteger64, int64](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching int64 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetInt64(vendorId, attrId uint32) int64 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterInteger64{}).(*layers.DiameterInteger64).Get()
+	return getValue[*DiameterInteger64, int64](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching float32 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetFloat32(vendorId, attrId uint32) float32 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterFloat32{}).(*layers.DiameterFloat32).Get()
+	return getValue[*DiameterFloat32, float32](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching float32 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetFloat32(vendorId, attrId uint32) float32 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterFloat32{}).(*layers.DiameterFloat32).Get()
+	return getValue[*DiameterFloat32, float32](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching float64 value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetFloat64(vendorId, attrId uint32) float64 {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterFloat64{}).(*layers.DiameterFloat64).Get()
+	return getValue[*DiameterFloat64, float64](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching float3264 value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetFloat64(vendorId, attrId uint32) float64 {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterFloat64{}).(*layers.DiameterFloat64).Get()
+	return getValue[*DiameterFloat64, float64](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching time.Time value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetTime(vendorId, attrId uint32) time.Time {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterTime{}).(*layers.DiameterTime).Get()
+	return getValue[*DiameterTime, time.Time](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching time.Time value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetTime(vendorId, attrId uint32) time.Time {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterTime{}).(*layers.DiameterTime).Get()
+	return getValue[*DiameterTime, time.Time](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching string value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetUTF8String(vendorId, attrId uint32) string {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterOctetString{}).(*layers.DiameterOctetString).Get()
+	return getValue[*DiameterOctetString, string](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching string value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetUTF8String(vendorId, attrId uint32) string {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterOctetString{}).(*layers.DiameterOctetString).Get()
+	return getValue[*DiameterOctetString, string](aip.at(vendorId, attrId))
 }
 
 // retrieve first matching net.IP value with given id, or the default/zero value for that type
 func (ai AvpIndexer) GetIPAddress(vendorId, attrId uint32) net.IP {
-	return ai.getDecoderIntfc(nil, vendorId, attrId, &layers.DiameterIPAddress{}).(*layers.DiameterIPAddress).Get()
+	return getValue[*DiameterIPAddress, net.IP](ai.at(vendorId, attrId))
 }
 
 // retrieve first matching net.IP value with given id, or the default/zero value for that type
 func (aip avpIndexerWithPath) GetIPAddress(vendorId, attrId uint32) net.IP {
-	return aip.getDecoderIntfc(aip.parent, vendorId, attrId, &layers.DiameterIPAddress{}).(*layers.DiameterIPAddress).Get()
-}
-
-// hacking around type safety so client can access typed values conveniently.  RFCs specify types for each AVP, so if
-// you ask for the wrong type your code has a bug.
-func (ai AvpIndexer) getDecoderIntfc(parent *pathElement, vendorId, attrId uint32, dfltVal interface{}) interface{} {
-	pe := pathElement{
-		avpId:  avpId{vendorId: vendorId, attrId: attrId},
-		parent: parent,
-	}
-	dif := ai.getDecoderIntfcp(&pe, dfltVal)
-	if dif != nil {
-		return dif
-	}
-	return dfltVal
-}
-
-func (ai AvpIndexer) getDecoderIntfcp(path *pathElement, dfltVal interface{}) interface{} {
-	for _, pe := range ai.index[path.avpId] {
-		if pe.matches(path) {
-			return pe.avp.GetDecoder()
-		}
-	}
-	return dfltVal
+	return getValue[*DiameterIPAddress, net.IP](aip.at(vendorId, attrId))
 }
 
 // invoke f for each matching AVP found.  returns number of times f was invoked.
-func (ai AvpIndexer) VisitAvp(vendorId, attrId uint32, f func(avp *layers.AVP)) int {
+func (ai AvpIndexer) VisitAvp(vendorId, attrId uint32, f func(avp *AVP)) int {
 	return ai.visitAvpp(nil, vendorId, attrId, f)
 }
 
 // invoke f for each matching AVP found.  returns number of times f was invoked.
-func (aip avpIndexerWithPath) VisitAvp(vendorId, attrId uint32, f func(avp *layers.AVP)) int {
+func (aip avpIndexerWithPath) VisitAvp(vendorId, attrId uint32, f func(avp *AVP)) int {
 	return aip.visitAvpp(aip.parent, vendorId, attrId, f)
 }
 
-func (ai AvpIndexer) visitAvpp(parent *pathElement, vendorId, attrId uint32, f func(avp *layers.AVP)) int {
+func (ai AvpIndexer) visitAvpp(parent *pathElement, vendorId, attrId uint32, f func(avp *AVP)) int {
 	pe := pathElement{
 		avpId:  avpId{vendorId: vendorId, attrId: attrId},
 		parent: parent,
 	}
 	return ai.visitIntfcp(&pe, f)
 }
-func (ai AvpIndexer) visitIntfcp(path *pathElement, f func(*layers.AVP)) int {
+func (ai AvpIndexer) visitIntfcp(path *pathElement, f func(*AVP)) int {
+	return ai.visitNodes(path, func(pe pathElementLeafNode) {
+		f(pe.avp)
+	})
+}
+
+func (ai AvpIndexer) visitNodes(path *pathElement, f func(pathElementLeafNode)) int {
 	var cc int
-	for _, pe := range ai.index[path.avpId] {
-		if pe.matches(path) {
-			cc++
-			f(pe.avp)
-		}
-	}
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		cc++
+		f(*pe)
+		return true
+	})
 	return cc
 }
 
-// add up uint64 vales for all matching AVPs
+// add up uint64 values of all matching AVPs; AVPs of other types are skipped, see Aggregates for those
 func (ai AvpIndexer) AccumulateUint64(vendorId, attrId uint32) uint64 {
 	var sum uint64
-	ai.VisitAvp(vendorId, attrId, func(avp *layers.AVP) {
-		sum += avp.GetDecoder().(*layers.DiameterUnsigned64).Get()
-	})
+	for _, v := range ai.GetAllUint64(vendorId, attrId) {
+		sum += v
+	}
 	return sum
 }
 
-// add up uint64 vales for all matching AVPs
+// add up uint64 values of all matching AVPs; AVPs of other types are skipped, see Aggregates for those
 func (aip avpIndexerWithPath) AccumulateUint64(vendorId, attrId uint32) uint64 {
 	var sum uint64
-	aip.VisitAvp(vendorId, attrId, func(avp *layers.AVP) {
-		sum += avp.GetDecoder().(*layers.DiameterUnsigned64).Get()
-	})
+	for _, v := range aip.GetAllUint64(vendorId, attrId) {
+		sum += v
+	}
 	return sum
 }
 
@@ -300,19 +415,59 @@ func (aip avpIndexerWithPath) FromGroup(vendorId, attrId uint32) avpIndexerWithP
 	return aip
 }
 
+// return indexer whose retrieval operations only consider top level AVPs, e.g. AtRoot().GetUint32(0, 485), or
+// AtRoot().FromGroup(10415, 873) for a Service-Information that is not nested in some other group.
+func (ai AvpIndexer) AtRoot() avpIndexerWithPath {
+	return avpIndexerWithPath{
+		AvpIndexer: ai,
+		parent:     rootPathElement,
+	}
+}
+
+// return indexer whose next group or retrieval operation matches at any depth below the current group, instead of
+// only direct children.
+func (aip avpIndexerWithPath) Descendants() avpIndexerWithPath {
+	aip.parent = descend(aip.parent)
+	return aip
+}
+
 // for 2 pathElements to match, their avpId has to match, AND their parents have to match, unless the parent in given
 // pathElement is nil.  Function is not symmetrical, x.matches(y) may not equal y.matches(x).
-// TODO support wildcard matches for vendor & attribute
+// Wildcard vendor or attribute ids in the given pathElement match any value at that level.  Marker elements in the
+// given path's parents anchor it at the message root (kindRoot) or allow any number of groups in between (kindDescend).
 func (p pathElement) matches(pe *pathElement) bool {
-	return pe == nil ||
-		p.vendorId == pe.vendorId && p.attrId == pe.attrId &&
-			(pe.parent == nil ||
-				p.parent != nil && p.parent.matches(pe.parent))
+	if pe == nil {
+		return true
+	}
+	return pe.avpId.matchesId(p.avpId) && aboveMatches(p.parent, pe.parent)
+}
+
+// true if indexed element x, the parent of some AVP (nil at the message root), satisfies query path q
+func aboveMatches(x *pathElement, q *pathElement) bool {
+	switch {
+	case q == nil:
+		return true
+	case q.kind == kindRoot:
+		return x == nil
+	case q.kind == kindNode:
+		return x == q.node
+	case q.kind == kindDescend:
+		if q.parent == nil || q.parent.kind == kindRoot {
+			return true
+		}
+		for anc := x; anc != nil; anc = anc.parent {
+			if aboveMatches(anc, q.parent) {
+				return true
+			}
+		}
+		return false
+	}
+	return x != nil && x.matches(q)
 }
 
 // Copy AVP decoded (string) values into a flat map value only if the key (AVP name, per RFC) exists in same map.
-// Clobbers previous values as found.
-func AddAvpDataToMap(avps []*layers.AVP, data map[string]string) {
+// Clobbers previous values as found.  Enumerated values are shown with their name, "STOP_RECORD (4)".
+func AddAvpDataToMap(avps []*AVP, data map[string]string) {
 	for _, avp := range avps {
 		if avp == nil {
 			continue
@@ -320,13 +475,13 @@ func AddAvpDataToMap(avps []*layers.AVP, data map[string]string) {
 		if len(avp.Grouped) > 0 {
 			AddAvpDataToMap(avp.Grouped, data)
 		} else if _, ok := data[avp.AttributeName]; ok {
-			data[avp.AttributeName] = avp.DecodedValue
+			data[avp.AttributeName] = displayValue(avp)
 		}
 	}
 }
 
 // Create flat map json string similar to AddAvpDataToMap()
-func JsonFromAvpFields(avps []*layers.AVP, includeFields []string) string {
+func JsonFromAvpFields(avps []*AVP, includeFields []string) string {
 	data := make(map[string]string)
 	for _, v := range includeFields {
 		data[v] = ""
@@ -337,14 +492,14 @@ func JsonFromAvpFields(avps []*layers.AVP, includeFields []string) string {
 }
 
 // Recursively prints AVP values to stdout, indenting with grouped sub-AVPs.
-func PrintAvps(d *layers.Diameter) {
+func PrintAvps(d *Diameter) {
 	for _, avp := range d.AVPs {
 		PrintAvp(avp, 0)
 	}
 }
 
 // Recursively prints AVP values to stdout, indenting with grouped sub-AVPs, starting at given indent level.
-func PrintAvp(avp *layers.AVP, indent int) {
+func PrintAvp(avp *AVP, indent int) {
 	if avp == nil {
 		return
 	}
@@ -358,11 +513,11 @@ func PrintAvp(avp *layers.AVP, indent int) {
 			PrintAvp(avp, indent+1)
 		}
 	} else {
-		fmt.Printf("%s%s(code=%d,vendor=%d,format=%s) = %s\n", is, avp.AttributeName, avp.AttributeCode, avp.VendorCode, avp.AttributeFormat, avp.DecodedValue)
+		fmt.Printf("%s%s(code=%d,vendor=%d,format=%s) = %s\n", is, avp.AttributeName, avp.AttributeCode, avp.VendorCode, avp.AttributeFormat, displayValue(avp))
 	}
 }
 
-func VisitAvp(avp *layers.AVP, visitor func(*layers.AVP)) {
+func VisitAvp(avp *AVP, visitor func(*AVP)) {
 	if avp == nil {
 		return
 	}
@@ -376,7 +531,7 @@ func VisitAvp(avp *layers.AVP, visitor func(*layers.AVP)) {
 }
 
 // Apply the visitor function to all AVPs contained in the diameter message.
-func VisitAvps(dmsg *layers.Diameter, visitor func(*layers.AVP)) {
+func VisitAvps(dmsg *Diameter, visitor func(*AVP)) {
 	for _, avp := range dmsg.AVPs {
 		VisitAvp(avp, visitor)
 	}
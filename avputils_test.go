@@ -2,16 +2,13 @@ package avpindexer
 
 import (
 	"fmt"
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/layers"
 	a "gotest.tools/assert"
 	"strings"
 	"testing"
+	"time"
 )
 
-var gpacket = gopacket.NewPacket(testPacketDiameterAccountingRequest271, layers.LayerTypeDiameter, gopacket.Default)
-var dial = gpacket.Layer(layers.LayerTypeDiameter)
-var d = dial.(*layers.Diameter)
+var d, _ = Decode(nil, testPacketDiameterAccountingRequest271)
 
 func TestPrint(t *testing.T) {
 	PrintAvps(d)
@@ -62,6 +59,59 @@ func TestPathMatch3(t *testing.T) {
 	a.Equal(t, ai.FromGroup(10415, 2040).GetUint64(0, 364999), uint64(0))
 }
 
+func TestWildcard(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.GetUint32(Wildcard, 2045), uint32(241))
+	a.Equal(t, ai.FromGroup(10415, 874).GetUint32(10415, Wildcard), uint32(0x5e9ed913)) // first 10415 avp in PS-Information
+	a.Equal(t, ai.FromGroup(Wildcard, 874).FromGroup(10415, Wildcard).GetUint32(10415, 2045), uint32(241))
+	a.DeepEqual(t, ai.FromGroup(10415, 873).FromGroup(Wildcard, Wildcard).GetIPAddress(10415, 1228), ai.FromGroup(10415, 874).GetIPAddress(10415, 1228))
+	a.Equal(t, ai.FromGroup(Wildcard, 2040).AccumulateUint64(Wildcard, 364), uint64(3208+26694))
+	a.Equal(t, ai.FromGroup(0, 2040).GetUint32(Wildcard, 2045), uint32(0))
+
+	var cc int
+	a.Equal(t, ai.FromGroup(10415, 2040).VisitAvp(Wildcard, Wildcard, func(avp *AVP) { cc++ }), 26)
+	a.Equal(t, cc, 26)
+
+	peW := pathElement{avpId: avpId{vendorId: wildcardValue, attrId: 3}}
+	peX := pathElement{avpId: avpId{vendorId: 4, attrId: 3}}
+	a.Assert(t, peX.matches(&peW))
+	a.Assert(t, !peW.matches(&peX))
+}
+
+func TestWildcardOtherTypes(t *testing.T) {
+	// the first AVP a wildcard matches is often of some other type, it's skipped rather than panicking
+	msg, err := NewMessage(271, 3, true).AVP(0, 263, "sess;1").AVP(0, 485, uint32(7)).
+		Group(0, 456, func(g *GroupBuilder) { g.AVP(0, 44, "x").AVP(0, 432, uint32(9)) }).Diameter()
+	a.NilError(t, err)
+	ai := NewAvpIndexer(msg)
+	a.Equal(t, ai.GetUint32(0, Wildcard), uint32(7))
+	a.Equal(t, ai.GetUTF8String(Wildcard, Wildcard), "sess;1")
+	a.Equal(t, ai.FromGroup(0, 456).GetUint32(Wildcard, Wildcard), uint32(9))
+	a.Equal(t, ai.AtRoot().FromGroup(0, 456).Descendants().GetUint32(0, Wildcard), uint32(9))
+	a.Equal(t, ai.Query("/0/456/*/*").GetUint32(), uint32(9))
+	a.Equal(t, ai.GetUint64(0, Wildcard), uint64(0))
+	a.Equal(t, ai.Query("0/263").GetTime(), time.Time{})
+}
+
+func TestAnchoredAndDescendant(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.AtRoot().GetUint32(0, 485), uint32(1))
+	a.Equal(t, ai.AtRoot().GetUint32(10415, 2045), uint32(0))
+	a.Equal(t, ai.AtRoot().FromGroup(10415, 874).GetUint32(10415, 2), uint32(0))
+	a.Equal(t, ai.AtRoot().FromGroup(10415, 873).FromGroup(10415, 874).GetUint32(10415, 2), uint32(0x5e9ed913))
+	a.Equal(t, ai.FromGroup(10415, 874).GetUint32(10415, 2045), uint32(0))
+	a.Equal(t, ai.FromGroup(10415, 874).Descendants().GetUint32(10415, 2045), uint32(241))
+	a.Equal(t, ai.AtRoot().FromGroup(10415, 873).Descendants().FromGroup(10415, 2040).AccumulateUint64(0, 364), uint64(3208+26694))
+	a.Equal(t, ai.AtRoot().Descendants().GetUint32(10415, 2045), uint32(241))
+	a.Equal(t, ai.FromGroup(10415, 873).Descendants().Descendants().AccumulateUint64(0, 364), uint64(3208+26694))
+
+	var cc int
+	ai.AtRoot().VisitAvp(Wildcard, Wildcard, func(avp *AVP) { cc++ })
+	a.Equal(t, cc, len(d.AVPs))
+}
+
 func TestAccumulate(t *testing.T) {
 	ai := NewAvpIndexer(d)
 
@@ -0,0 +1,238 @@
+package avpindexer
+
+import (
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fluent message building, for tests and simulators:
+//
+//	b, err := NewMessage(272, 4, true).
+//		AVP(0, 263, "sess;1").
+//		AVPByName("CC-Request-Type", "UPDATE_REQUEST").
+//		Group(0, 456, func(g *GroupBuilder) {
+//			g.AVP(0, 432, uint32(100)).Group(0, 446, func(g *GroupBuilder) {
+//				g.AVP(0, 421, uint64(1<<20))
+//			})
+//		}).
+//		Bytes()
+//
+// Values are encoded as for Marshal: by the AVP's dictionary type, with the flags the dictionary requires; strings
+// name values of Enumerated AVPs, slices add one AVP per element and tagged structs make grouped AVPs.  The first
+// error is kept and returned by Bytes or Diameter, later calls are ignored.  Hop-by-hop and end-to-end ids are
+// generated per RFC 6733 section 3 unless set with Ids.
+
+// MessageBuilder builds a message, see NewMessage.
+type MessageBuilder struct {
+	h    Header
+	root avpNode
+	err  error
+}
+
+// GroupBuilder adds sub-AVPs to a grouped AVP, see MessageBuilder.Group.
+type GroupBuilder struct {
+	m *MessageBuilder
+	n *avpNode
+}
+
+// Start a message with given command code and application id, a request or an answer.
+func NewMessage(cmd, appId uint32, request bool) *MessageBuilder {
+	m := &MessageBuilder{h: Header{CommandCode: cmd, ApplicationID: appId}}
+	if request {
+		m.h.Flags = FlagRequest
+	}
+	m.h.HopByHopID, m.h.EndToEndID = nextIds()
+	return m
+}
+
+// Start the answer to req: same command code, application id and ids, and the P flag if req has it.
+func NewAnswer(req *Diameter) *MessageBuilder {
+	return &MessageBuilder{h: Header{
+		CommandCode:   req.CommandCode,
+		ApplicationID: req.ApplicationID,
+		Flags:         req.Flags & FlagProxiable,
+		HopByHopID:    req.HopByHopID,
+		EndToEndID:    req.EndToEndID,
+	}}
+}
+
+// hop-by-hop ids count up from a random start; end-to-end ids have the low 12 bits of the start time in their
+// high bits and a random start in the low 20, RFC 6733 section 3
+var msgIds struct {
+	once               sync.Once
+	hopByHop, endToEnd uint32
+}
+
+func nextIds() (hopByHop, endToEnd uint32) {
+	msgIds.once.Do(func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		msgIds.hopByHop = r.Uint32()
+		msgIds.endToEnd = uint32(time.Now().Unix())<<20 | r.Uint32()&0xfffff
+	})
+	return atomic.AddUint32(&msgIds.hopByHop, 1), atomic.AddUint32(&msgIds.endToEnd, 1)
+}
+
+// Use d for AVP names, types and flags instead of dictionary.Default(); call before adding AVPs.
+func (m *MessageBuilder) WithDictionary(d *dictionary.Dictionary) *MessageBuilder {
+	m.h.Dictionary = d
+	return m
+}
+
+// Set the header flags, FlagRequest etc.
+func (m *MessageBuilder) Flags(flags uint8) *MessageBuilder {
+	m.h.Flags = flags
+	return m
+}
+
+// Set the hop-by-hop and end-to-end ids instead of generated ones.
+func (m *MessageBuilder) Ids(hopByHop, endToEnd uint32) *MessageBuilder {
+	m.h.HopByHopID, m.h.EndToEndID = hopByHop, endToEnd
+	return m
+}
+
+// Add an AVP with given id and value.
+func (m *MessageBuilder) AVP(vendorId, attrId uint32, v interface{}) *MessageBuilder {
+	m.add(&m.root, avpId{vendorId: vendorId, attrId: attrId}, "", v)
+	return m
+}
+
+// Add an AVP by dictionary name, vendor:name if several vendors define it.
+func (m *MessageBuilder) AVPByName(name string, v interface{}) *MessageBuilder {
+	m.addByName(&m.root, name, v)
+	return m
+}
+
+// Add a grouped AVP with given id, f adds its sub-AVPs.
+func (m *MessageBuilder) Group(vendorId, attrId uint32, f func(g *GroupBuilder)) *MessageBuilder {
+	m.group(&m.root, avpId{vendorId: vendorId, attrId: attrId}, f)
+	return m
+}
+
+// Add a grouped AVP by dictionary name, f adds its sub-AVPs.
+func (m *MessageBuilder) GroupByName(name string, f func(g *GroupBuilder)) *MessageBuilder {
+	m.groupByName(&m.root, name, f)
+	return m
+}
+
+// Header of the message as built so far.
+func (m *MessageBuilder) Header() Header {
+	return m.h
+}
+
+// First error from building, nil if there is none.
+func (m *MessageBuilder) Err() error {
+	return m.err
+}
+
+// Encoded message, or the first error from building it.
+func (m *MessageBuilder) Bytes() ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	avps, err := m.root.appendChildren(nil)
+	if err != nil {
+		return nil, err
+	}
+	return appendMessage(nil, diameterVersion, m.h.Flags, m.h.CommandCode, m.h.ApplicationID, m.h.HopByHopID, m.h.EndToEndID, avps)
+}
+
+// Same as Bytes, decoded into a message as if it were captured.
+func (m *MessageBuilder) Diameter() (*Diameter, error) {
+	b, err := m.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return Decode(m.dictionary(), b)
+}
+
+// Add an AVP with given id and value.
+func (g *GroupBuilder) AVP(vendorId, attrId uint32, v interface{}) *GroupBuilder {
+	g.m.add(g.n, avpId{vendorId: vendorId, attrId: attrId}, "", v)
+	return g
+}
+
+// Add an AVP by dictionary name, vendor:name if several vendors define it.
+func (g *GroupBuilder) AVPByName(name string, v interface{}) *GroupBuilder {
+	g.m.addByName(g.n, name, v)
+	return g
+}
+
+// Add a grouped AVP with given id, f adds its sub-AVPs.
+func (g *GroupBuilder) Group(vendorId, attrId uint32, f func(g *GroupBuilder)) *GroupBuilder {
+	g.m.group(g.n, avpId{vendorId: vendorId, attrId: attrId}, f)
+	return g
+}
+
+// Add a grouped AVP by dictionary name, f adds its sub-AVPs.
+func (g *GroupBuilder) GroupByName(name string, f func(g *GroupBuilder)) *GroupBuilder {
+	g.m.groupByName(g.n, name, f)
+	return g
+}
+
+func (m *MessageBuilder) dictionary() *dictionary.Dictionary {
+	if m.h.Dictionary == nil {
+		return dictionary.Default()
+	}
+	return m.h.Dictionary
+}
+
+func (m *MessageBuilder) add(n *avpNode, id avpId, name string, v interface{}) {
+	if m.err != nil {
+		return
+	}
+	if name == "" {
+		name = fmt.Sprintf("%d/%d", id.vendorId, id.attrId)
+	}
+	if v == nil || id.hasWildcard() {
+		m.err = fmt.Errorf("AVP %s: %w: nil value or wildcard id", name, ErrAvpEncode)
+		return
+	}
+	if err := marshalField(m.dictionary(), n, id, reflect.ValueOf(v), name); err != nil {
+		m.err = fmt.Errorf("AVP %w", err)
+	}
+}
+
+func (m *MessageBuilder) addByName(n *avpNode, name string, v interface{}) {
+	if m.err != nil {
+		return
+	}
+	id, err := resolveName(m.dictionary(), name)
+	if err != nil {
+		m.err = fmt.Errorf("AVP %s: %w", name, err)
+		return
+	}
+	m.add(n, id, name, v)
+}
+
+func (m *MessageBuilder) group(n *avpNode, id avpId, f func(g *GroupBuilder)) {
+	if m.err != nil {
+		return
+	}
+	if id.hasWildcard() {
+		m.err = fmt.Errorf("Group %d/%d: %w: wildcard id", id.vendorId, id.attrId, ErrAvpEncode)
+		return
+	}
+	c := n.add(m.dictionary(), id, true)
+	if c.def != nil && c.def.Type != dictionary.Grouped {
+		m.err = fmt.Errorf("Group %s is not Grouped", avpDesc(c))
+		return
+	}
+	f(&GroupBuilder{m: m, n: c})
+}
+
+func (m *MessageBuilder) groupByName(n *avpNode, name string, f func(g *GroupBuilder)) {
+	if m.err != nil {
+		return
+	}
+	id, err := resolveName(m.dictionary(), name)
+	if err != nil {
+		m.err = fmt.Errorf("Group %s: %w", name, err)
+		return
+	}
+	m.group(n, id, f)
+}
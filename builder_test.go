@@ -0,0 +1,117 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	ts := time.Date(2019, 10, 8, 15, 34, 59, 0, time.UTC)
+	m := NewMessage(272, 4, true).
+		AVP(0, 263, "sess;1").
+		AVPByName("Origin-Host", "pcef.example.com").
+		AVPByName("CC-Request-Type", "UPDATE_REQUEST").
+		AVP(0, 415, 1).
+		AVP(0, 55, ts).
+		AVP(0, 257, []net.IP{net.IPv4(10, 0, 0, 1), net.ParseIP("2001:db8::1")}).
+		Group(0, 456, func(g *GroupBuilder) {
+			g.AVP(0, 432, uint32(100)).Group(0, 446, func(g *GroupBuilder) {
+				g.AVP(0, 421, uint64(1<<20)).AVP(0, 420, uint32(60))
+			})
+		}).
+		Group(0, 456, func(g *GroupBuilder) {
+			g.AVP(0, 432, uint32(200))
+		}).
+		GroupByName("Service-Information", func(g *GroupBuilder) {
+			g.GroupByName("PS-Information", func(g *GroupBuilder) {
+				g.AVP(10415, 2037, int32(-1)).AVP(10415, 21, []byte{6})
+			})
+		})
+	dia, err := m.Diameter()
+	a.NilError(t, err)
+	a.Equal(t, dia.CommandCode, uint32(272))
+	a.Equal(t, dia.ApplicationID, uint32(4))
+	a.Equal(t, dia.Flags, FlagRequest)
+	a.Equal(t, dia.HopByHopID, m.Header().HopByHopID)
+
+	ai := NewAvpIndexer(dia)
+	a.Equal(t, ai.GetUTF8String(0, 263), "sess;1")
+	a.Equal(t, ai.GetUTF8String(0, 264), "pcef.example.com")
+	a.Equal(t, ai.GetEnum(0, 416), Enum{Value: 2, Name: "UPDATE_REQUEST"})
+	a.Equal(t, ai.GetUint32(0, 415), uint32(1))
+	a.Equal(t, ai.GetTime(0, 55), ts)
+	ips := ai.GetAllIPAddress(0, 257)
+	a.Equal(t, len(ips), 2)
+	a.Equal(t, ips[0].String(), "10.0.0.1")
+	a.Equal(t, ips[1].String(), "2001:db8::1")
+	a.DeepEqual(t, ai.FromGroup(0, 456).GetAllUint32(0, 432), []uint32{100, 200})
+	a.Equal(t, ai.Query("0/456/0/446/0/421").GetUint64(), uint64(1<<20))
+	a.Equal(t, ai.Query("**/10415/2037").GetInt32(), int32(-1))
+	a.DeepEqual(t, GetAll[[]byte](ai.FromGroup(10415, 874), 10415, 21), [][]byte{{6}})
+
+	// the same message again from its bytes
+	b, err := m.Bytes()
+	a.NilError(t, err)
+	again, err := Decode(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, again.MessageLen, uint32(len(b)))
+}
+
+func TestMessageBuilderTypes(t *testing.T) {
+	d := dictionary.Default().Clone()
+	d.AddAVP(&dictionary.AVP{Name: "Test-Float", Code: 1, VendorID: 99, Type: dictionary.Float64})
+	d.AddAVP(&dictionary.AVP{Name: "Test-Int64", Code: 2, VendorID: 99, Type: dictionary.Integer64})
+	d.AddAVP(&dictionary.AVP{Name: "Test-Float32", Code: 3, VendorID: 99, Type: dictionary.Float32})
+	dia, err := NewMessage(1, 0, false).WithDictionary(d).
+		AVP(99, 1, 2.5).
+		AVP(99, 2, -7).
+		AVP(99, 3, float32(0.5)).
+		Diameter()
+	a.NilError(t, err)
+	ai := NewAvpIndexer(dia).WithDictionary(d)
+	a.Equal(t, ai.GetValue(99, 1), 2.5)
+	a.Equal(t, ai.GetValue(99, 2), int64(-7))
+	a.Equal(t, ai.GetValue(99, 3), float32(0.5))
+	a.Equal(t, dia.Flags, uint8(0))
+}
+
+func TestMessageBuilderIds(t *testing.T) {
+	h1 := NewMessage(272, 4, true).Header()
+	h2 := NewMessage(272, 4, true).Header()
+	a.Equal(t, h2.HopByHopID, h1.HopByHopID+1)
+	a.Equal(t, h2.EndToEndID, h1.EndToEndID+1)
+
+	req, err := NewMessage(272, 4, true).Flags(FlagRequest|FlagProxiable).Ids(10, 20).AVP(0, 263, "s").Diameter()
+	a.NilError(t, err)
+	ans, err := NewAnswer(req).AVP(0, 268, uint32(2001)).Diameter()
+	a.NilError(t, err)
+	a.Equal(t, ans.Flags, FlagProxiable)
+	a.Equal(t, ans.HopByHopID, uint32(10))
+	a.Equal(t, ans.EndToEndID, uint32(20))
+	a.Equal(t, ans.CommandCode, uint32(272))
+}
+
+func TestMessageBuilderErrors(t *testing.T) {
+	_, err := NewMessage(272, 4, true).AVP(0, 432, "x").AVP(0, 263, "s").Bytes()
+	a.Assert(t, errors.Is(err, ErrAvpEncode))
+	a.ErrorContains(t, err, "AVP 0/432, Rating-Group (0/432)")
+
+	_, err = NewMessage(272, 4, true).AVPByName("CC-Request-Type", "NO_SUCH").Bytes()
+	a.ErrorContains(t, err, `no value "NO_SUCH"`)
+
+	_, err = NewMessage(272, 4, true).AVPByName("No-Such-AVP", 1).Bytes()
+	a.Assert(t, errors.Is(err, dictionary.ErrUnknownAVP))
+
+	_, err = NewMessage(272, 4, true).Group(0, 263, func(g *GroupBuilder) {}).Bytes()
+	a.ErrorContains(t, err, "is not Grouped")
+
+	_, err = NewMessage(272, 4, true).AVP(0, 263, nil).Bytes()
+	a.Assert(t, errors.Is(err, ErrAvpEncode))
+
+	_, err = NewMessage(272, 4, true).AVP(Wildcard, 263, "s").Bytes()
+	a.Assert(t, errors.Is(err, ErrAvpEncode))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type options struct {
+	pkg     string
+	vendors map[uint32]bool // only AVPs of these vendors, all if empty
+	only    map[string]bool // only AVPs with these names (lower case), all if empty
+}
+
+// Lookup method of avpindexer.Indexer used for each type; OctetString goes through the generic Lookup
+var lookupMethods = map[dictionary.Type]struct{ method, goType string }{
+	dictionary.Unsigned32:       {"LookupUint32", "uint32"},
+	dictionary.Unsigned64:       {"LookupUint64", "uint64"},
+	dictionary.Integer32:        {"LookupInt32", "int32"},
+	dictionary.Integer64:        {"LookupInt64", "int64"},
+	dictionary.Float32:          {"LookupFloat32", "float32"},
+	dictionary.Float64:          {"LookupFloat64", "float64"},
+	dictionary.Time:             {"LookupTime", "time.Time"},
+	dictionary.Address:          {"LookupIPAddress", "net.IP"},
+	dictionary.UTF8String:       {"LookupUTF8String", "string"},
+	dictionary.DiameterIdentity: {"LookupUTF8String", "string"},
+	dictionary.DiameterURI:      {"LookupUTF8String", "string"},
+	dictionary.IPFilterRule:     {"LookupUTF8String", "string"},
+	dictionary.QoSFilterRule:    {"LookupUTF8String", "string"},
+}
+
+type genAVP struct {
+	*dictionary.AVP
+	goName string
+	vendor string // name of the vendor constant
+}
+
+// write Go source for the selected AVPs of d to w
+func generate(w io.Writer, d *dictionary.Dictionary, opts options) error {
+	var avps []genAVP
+	names := make(map[string]*dictionary.AVP)
+	vendors := make(map[uint32]string)
+	for _, a := range d.AVPs() {
+		if len(opts.vendors) > 0 && !opts.vendors[a.VendorID] {
+			continue
+		}
+		if len(opts.only) > 0 && !opts.only[strings.ToLower(a.Name)] {
+			continue
+		}
+		g := genAVP{AVP: a, goName: goName(a.Name)}
+		if prev := names[g.goName]; prev != nil {
+			return fmt.Errorf("%s (%d/%d) and %s (%d/%d) both map to Go name %s, use -vendor or -only to pick one",
+				prev.Name, prev.VendorID, prev.Code, a.Name, a.VendorID, a.Code, g.goName)
+		}
+		names[g.goName] = a
+		if _, ok := vendors[a.VendorID]; !ok {
+			vendors[a.VendorID] = vendorConst(d, a.VendorID)
+		}
+		g.vendor = vendors[a.VendorID]
+		avps = append(avps, g)
+	}
+	if len(avps) == 0 {
+		return fmt.Errorf("no AVPs selected")
+	}
+	if len(opts.only) > 0 {
+		for n := range opts.only {
+			if _, err := d.AVPByName(n); err != nil {
+				return err
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by avpgen; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", opts.pkg)
+	b.WriteString("import (\n")
+	for _, imp := range imports(avps) {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// vendor ids\nconst (\n")
+	for _, id := range sortedIds(vendors) {
+		fmt.Fprintf(&b, "\t%s uint32 = %d\n", vendors[id], id)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("// AVP codes\nconst (\n")
+	for _, a := range avps {
+		fmt.Fprintf(&b, "\tCode%s uint32 = %d // %s, %s\n", a.goName, a.Code, a.Name, a.Type)
+	}
+	b.WriteString(")\n")
+
+	for _, a := range avps {
+		if a.Type == dictionary.Enumerated {
+			writeEnum(&b, a)
+		}
+	}
+	for _, a := range avps {
+		writeAccessor(&b, a)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func imports(avps []genAVP) []string {
+	need := map[string]bool{"github.com/rjm2718/avpindexer": true}
+	for _, a := range avps {
+		switch a.Type {
+		case dictionary.Time:
+			need["time"] = true
+		case dictionary.Address:
+			need["net"] = true
+		case dictionary.Enumerated:
+			need["strconv"] = true
+		}
+	}
+	l := make([]string, 0, len(need))
+	for imp := range need {
+		l = append(l, imp)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func writeEnum(b *bytes.Buffer, a genAVP) {
+	typ := a.goName + "Enum"
+	fmt.Fprintf(b, "\n// %s values of %s\ntype %s uint32\n\n", typ, a.Name, typ)
+	if len(a.Enums) == 0 {
+		fmt.Fprintf(b, "func (v %s) String() string {\n\treturn strconv.FormatUint(uint64(v), 10)\n}\n", typ)
+		return
+	}
+	seen := make(map[string]bool)
+	consts := make([]string, len(a.Enums))
+	b.WriteString("const (\n")
+	for i, e := range a.Enums {
+		c := a.goName + goName(strings.ToLower(e.Name))
+		if seen[c] {
+			c += strconv.FormatUint(uint64(e.Value), 10)
+		}
+		seen[c] = true
+		consts[i] = c
+		fmt.Fprintf(b, "\t%s %s = %d\n", c, typ, e.Value)
+	}
+	b.WriteString(")\n\n")
+	fmt.Fprintf(b, "func (v %s) String() string {\n\tswitch v {\n", typ)
+	done := make(map[uint32]bool)
+	for i, e := range a.Enums {
+		if done[e.Value] {
+			continue
+		}
+		done[e.Value] = true
+		fmt.Fprintf(b, "\tcase %s:\n\t\treturn %q\n", consts[i], e.Name)
+	}
+	b.WriteString("\t}\n\treturn strconv.FormatUint(uint64(v), 10)\n}\n")
+}
+
+func writeAccessor(b *bytes.Buffer, a genAVP) {
+	fmt.Fprintf(b, "\n")
+	switch a.Type {
+	case dictionary.Grouped:
+		fmt.Fprintf(b, "// %s returns an indexer scoped to the %s group\n", a.goName, a.Name)
+		fmt.Fprintf(b, "func %s(ix avpindexer.Indexer) avpindexer.Indexer {\n", a.goName)
+		fmt.Fprintf(b, "\treturn ix.FromGroup(%s, Code%s)\n}\n", a.vendor, a.goName)
+	case dictionary.Enumerated:
+		fmt.Fprintf(b, "// %s returns the first %s value; false if there is none\n", a.goName, a.Name)
+		fmt.Fprintf(b, "func %s(ix avpindexer.Indexer) (%sEnum, bool) {\n", a.goName, a.goName)
+		fmt.Fprintf(b, "\tv, ok := ix.LookupEnumerated(%s, Code%s)\n", a.vendor, a.goName)
+		fmt.Fprintf(b, "\treturn %sEnum(v), ok\n}\n", a.goName)
+	case dictionary.OctetString:
+		fmt.Fprintf(b, "// %s returns the first %s value; false if there is none\n", a.goName, a.Name)
+		fmt.Fprintf(b, "func %s(ix avpindexer.Indexer) ([]byte, bool) {\n", a.goName)
+		fmt.Fprintf(b, "\treturn avpindexer.Lookup[[]byte](ix, %s, Code%s)\n}\n", a.vendor, a.goName)
+	default:
+		lm, ok := lookupMethods[a.Type]
+		if !ok {
+			// no accessor for types the indexer can't read, the code constant still helps
+			fmt.Fprintf(b, "// no accessor for %s, type %s\n", a.Name, a.Type)
+			return
+		}
+		fmt.Fprintf(b, "// %s returns the first %s value; false if there is none\n", a.goName, a.Name)
+		fmt.Fprintf(b, "func %s(ix avpindexer.Indexer) (%s, bool) {\n", a.goName, lm.goType)
+		fmt.Fprintf(b, "\treturn ix.%s(%s, Code%s)\n}\n", lm.method, a.vendor, a.goName)
+	}
+}
+
+// Go identifier for a dictionary name: words split at punctuation, each capitalized and joined, so Rating-Group
+// becomes RatingGroup.  Enum names are passed in lower case, INITIAL_REQUEST becomes InitialRequest.  Names
+// starting with a digit get an Avp prefix.
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	n := b.String()
+	if n == "" || unicode.IsDigit([]rune(n)[0]) {
+		n = "Avp" + n
+	}
+	return n
+}
+
+func vendorConst(d *dictionary.Dictionary, id uint32) string {
+	if id == 0 {
+		return "VendorIETF"
+	}
+	if v := d.Vendor(id); v != nil && v.Name != "" {
+		return "Vendor" + strings.TrimPrefix(goName(v.Name), "Avp")
+	}
+	return "Vendor" + strconv.FormatUint(uint64(id), 10)
+}
+
+func sortedIds(m map[uint32]string) []uint32 {
+	ids := make([]uint32, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	var b bytes.Buffer
+	err := generate(&b, dictionary.Default(), options{pkg: "avps", only: map[string]bool{
+		"rating-group": true, "cc-request-type": true, "service-data-container": true, "3gpp-charging-id": true,
+		"time-first-usage": true, "sgsn-address": true, "3gpp-rat-type": true,
+	}})
+	a.NilError(t, err)
+	// ignore gofmt's alignment of const blocks
+	src := regexp.MustCompile(` +`).ReplaceAllString(b.String(), " ")
+
+	for _, want := range []string{
+		"// Code generated by avpgen; DO NOT EDIT.",
+		"package avps",
+		"\t\"net\"\n",
+		"\t\"time\"\n",
+		"VendorIETF uint32 = 0",
+		"Vendor3GPP uint32 = 10415",
+		"CodeRatingGroup uint32 = 432",
+		"CodeAvp3GPPChargingId",
+		"type CCRequestTypeEnum uint32",
+		"CCRequestTypeTerminationRequest CCRequestTypeEnum = 3",
+		"case CCRequestTypeTerminationRequest:\n\t\treturn \"TERMINATION_REQUEST\"",
+		"func RatingGroup(ix avpindexer.Indexer) (uint32, bool) {\n\treturn ix.LookupUint32(VendorIETF, CodeRatingGroup)",
+		"func CCRequestType(ix avpindexer.Indexer) (CCRequestTypeEnum, bool) {",
+		"func ServiceDataContainer(ix avpindexer.Indexer) avpindexer.Indexer {\n\treturn ix.FromGroup(Vendor3GPP, CodeServiceDataContainer)",
+		"func TimeFirstUsage(ix avpindexer.Indexer) (time.Time, bool) {",
+		"func SGSNAddress(ix avpindexer.Indexer) (net.IP, bool) {",
+		"return avpindexer.Lookup[[]byte](ix, Vendor3GPP, CodeAvp3GPPRATType)",
+	} {
+		a.Assert(t, strings.Contains(src, want), want)
+	}
+}
+
+func TestGenerateAll(t *testing.T) {
+	// the whole bundled dictionary generates without name clashes
+	var b bytes.Buffer
+	a.NilError(t, generate(&b, dictionary.Default(), options{pkg: "avps"}))
+	a.Assert(t, strings.Contains(b.String(), "func ServiceInformation("))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	d := dictionary.Default().Clone()
+	d.AddAVP(&dictionary.AVP{Name: "Rating_Group", Code: 7, VendorID: 9, Type: dictionary.Unsigned32})
+	var b bytes.Buffer
+	err := generate(&b, d, options{pkg: "avps"})
+	a.ErrorContains(t, err, "both map to Go name RatingGroup")
+	a.Equal(t, b.Len(), 0)
+
+	err = generate(&b, d, options{pkg: "avps", only: map[string]bool{"rating-group": true, "no-such-avp": true}})
+	a.ErrorContains(t, err, `unknown avp "no-such-avp"`)
+
+	err = generate(&b, d, options{pkg: "avps", vendors: map[uint32]bool{12345: true}})
+	a.ErrorContains(t, err, "no AVPs selected")
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"Rating-Group":         "RatingGroup",
+		"3GPP-Charging-Id":     "Avp3GPPChargingId",
+		"initial_request":      "InitialRequest",
+		"gw/pcef_malfunction":  "GwPcefMalfunction",
+		"QoS-Class-Identifier": "QoSClassIdentifier",
+	} {
+		a.Equal(t, goName(in), want)
+	}
+}
@@ -0,0 +1,355 @@
+package avpindexer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Diameter wire decoding, RFC 6733 sections 3 and 4.  Decode parses a message into Diameter and AVP values; each
+// AVP's type, and so the decoder its value is read with, comes from a dictionary, and grouped AVPs are parsed into
+// their sub-AVPs.
+//
+//	dia, err := Decode(nil, packet)          bundled dictionary
+//	ai := NewAvpIndexer(dia)
+//
+// The types mirror those of the gopacket Diameter layer this package was first written against; the gopacketadapter
+// module decodes messages out of packets captured with gopacket.
+
+// ErrMalformed is returned (wrapped) by Decode for data that isn't a well formed Diameter message.
+var ErrMalformed = errors.New("malformed diameter message")
+
+// Diameter is a decoded Diameter message.
+type Diameter struct {
+	Version       uint8
+	Flags         uint8 // FlagRequest etc
+	MessageLen    uint32
+	CommandCode   uint32
+	ApplicationID uint32
+	HopByHopID    uint32
+	EndToEndID    uint32
+	AVPs          []*AVP
+	dict          *dictionary.Dictionary // decoded with, nil if not decoded by Decode or DecodeLazy
+}
+
+// AVP is a decoded AVP.  Name, format and decoder follow its dictionary definition; AVPs missing from the dictionary
+// have no name and are read as OctetString.
+type AVP struct {
+	AttributeCode   uint32
+	AttributeName   string
+	AttributeFormat string // dictionary type, "Unsigned32" etc
+	Flags           uint8
+	HeaderLen       uint32 // 8, or 12 with a vendor id
+	Len             uint32 // header and data, padding not included
+	VendorCode      uint32
+	Data            []byte
+	Padding         uint32
+	DecodedValue    string // value as text, empty for grouped AVPs or data that doesn't decode; see DecodeLazy
+	Grouped         []*AVP
+	decoder         DiameterDecoder
+	decodeErr       error                  // why Data didn't decode as the decoder's type; the decoder then holds zero
+	unpadded        bool                   // decoded without its padding, as the last AVP of its message or group
+	dict            *dictionary.Dictionary // decoded with; names the value of an Enumerated AVP
+	lazy            *lazyMessage           // for AVPs from DecodeLazy, whose value is decoded on first use
+	decoded         uint32                 // lazy AVP's value decoded, atomic
+}
+
+// decoder holding the AVP's value, nil for AVPs that weren't decoded by Decode or DecodeLazy
+func (a *AVP) GetDecoder() DiameterDecoder {
+	if a.lazy != nil && atomic.LoadUint32(&a.decoded) == 0 {
+		a.lazy.decode(a)
+	}
+	return a.decoder
+}
+
+// DiameterDecoder reads an AVP's data as one of the Diameter types; the typed getters ask for a particular one, so
+// GetUint32 wants the *DiameterUnsigned32 of an Unsigned32 AVP.
+type DiameterDecoder interface {
+	String() string
+	decode(b []byte) error
+}
+
+type DiameterUnsigned32 struct{ v uint32 }
+type DiameterUnsigned64 struct{ v uint64 }
+type DiameterInteger32 struct{ v int32 }
+type DiameterInteger64 struct{ v int64 }
+type DiameterFloat32 struct{ v float32 }
+type DiameterFloat64 struct{ v float64 }
+type DiameterEnumerated struct{ v uint32 }
+type DiameterTime struct{ v time.Time }
+type DiameterOctetString struct{ v string } // also the string types: UTF8String, DiameterIdentity etc
+type DiameterIPAddress struct{ v net.IP }
+type DiameterGrouped struct{}
+
+func dataLen(b []byte, n int) error {
+	if len(b) != n {
+		return fmt.Errorf("data length %d, expected %d", len(b), n)
+	}
+	return nil
+}
+
+func (d *DiameterUnsigned32) decode(b []byte) error {
+	if err := dataLen(b, 4); err != nil {
+		return err
+	}
+	d.v = binary.BigEndian.Uint32(b)
+	return nil
+}
+
+func (d *DiameterUnsigned32) Get() uint32    { return d.v }
+func (d *DiameterUnsigned32) String() string { return strconv.FormatUint(uint64(d.v), 10) }
+
+func (d *DiameterUnsigned64) decode(b []byte) error {
+	if err := dataLen(b, 8); err != nil {
+		return err
+	}
+	d.v = binary.BigEndian.Uint64(b)
+	return nil
+}
+
+func (d *DiameterUnsigned64) Get() uint64    { return d.v }
+func (d *DiameterUnsigned64) String() string { return strconv.FormatUint(d.v, 10) }
+
+func (d *DiameterInteger32) decode(b []byte) error {
+	if err := dataLen(b, 4); err != nil {
+		return err
+	}
+	d.v = int32(binary.BigEndian.Uint32(b))
+	return nil
+}
+
+func (d *DiameterInteger32) Get() int32     { return d.v }
+func (d *DiameterInteger32) String() string { return strconv.FormatInt(int64(d.v), 10) }
+
+func (d *DiameterInteger64) decode(b []byte) error {
+	if err := dataLen(b, 8); err != nil {
+		return err
+	}
+	d.v = int64(binary.BigEndian.Uint64(b))
+	return nil
+}
+
+func (d *DiameterInteger64) Get() int64     { return d.v }
+func (d *DiameterInteger64) String() string { return strconv.FormatInt(d.v, 10) }
+
+func (d *DiameterFloat32) decode(b []byte) error {
+	if err := dataLen(b, 4); err != nil {
+		return err
+	}
+	d.v = math.Float32frombits(binary.BigEndian.Uint32(b))
+	return nil
+}
+
+func (d *DiameterFloat32) Get() float32   { return d.v }
+func (d *DiameterFloat32) String() string { return strconv.FormatFloat(float64(d.v), 'g', -1, 32) }
+
+func (d *DiameterFloat64) decode(b []byte) error {
+	if err := dataLen(b, 8); err != nil {
+		return err
+	}
+	d.v = math.Float64frombits(binary.BigEndian.Uint64(b))
+	return nil
+}
+
+func (d *DiameterFloat64) Get() float64   { return d.v }
+func (d *DiameterFloat64) String() string { return strconv.FormatFloat(d.v, 'g', -1, 64) }
+
+func (d *DiameterEnumerated) decode(b []byte) error {
+	if err := dataLen(b, 4); err != nil {
+		return err
+	}
+	d.v = binary.BigEndian.Uint32(b)
+	return nil
+}
+
+func (d *DiameterEnumerated) Get() uint32    { return d.v }
+func (d *DiameterEnumerated) String() string { return strconv.FormatUint(uint64(d.v), 10) }
+
+func (d *DiameterTime) decode(b []byte) error {
+	v, err := decodeData(dictionary.Time, b)
+	if err != nil {
+		return err
+	}
+	d.v = v.(time.Time)
+	return nil
+}
+
+func (d *DiameterTime) Get() time.Time { return d.v }
+func (d *DiameterTime) String() string { return d.v.String() }
+
+func (d *DiameterOctetString) decode(b []byte) error {
+	d.v = string(b)
+	return nil
+}
+
+func (d *DiameterOctetString) Get() string    { return d.v }
+func (d *DiameterOctetString) String() string { return d.v }
+
+func (d *DiameterIPAddress) decode(b []byte) error {
+	v, err := decodeData(dictionary.Address, b)
+	if err != nil {
+		return err
+	}
+	d.v = v.(net.IP)
+	return nil
+}
+
+func (d *DiameterIPAddress) Get() net.IP    { return d.v }
+func (d *DiameterIPAddress) String() string { return d.v.String() }
+
+func (d *DiameterGrouped) decode([]byte) error { return nil }
+func (d *DiameterGrouped) String() string      { return "" }
+
+// decoder for AVPs of type t; OctetString for unknown types
+func newDecoder(t dictionary.Type) DiameterDecoder {
+	switch t {
+	case dictionary.Unsigned32:
+		return &DiameterUnsigned32{}
+	case dictionary.Unsigned64:
+		return &DiameterUnsigned64{}
+	case dictionary.Integer32:
+		return &DiameterInteger32{}
+	case dictionary.Integer64:
+		return &DiameterInteger64{}
+	case dictionary.Float32:
+		return &DiameterFloat32{}
+	case dictionary.Float64:
+		return &DiameterFloat64{}
+	case dictionary.Enumerated:
+		return &DiameterEnumerated{}
+	case dictionary.Time:
+		return &DiameterTime{}
+	case dictionary.Address:
+		return &DiameterIPAddress{}
+	case dictionary.Grouped:
+		return &DiameterGrouped{}
+	}
+	return &DiameterOctetString{}
+}
+
+// Decode a Diameter message, with AVP types from d (dictionary.Default() if nil).  b is copied, bytes past the
+// message length are ignored.  Data that doesn't decode as its AVP's type is no error, the typed getters report it;
+// lengths that don't add up are, see ErrMalformed.
+func Decode(d *dictionary.Dictionary, b []byte) (*Diameter, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	msg := &Diameter{}
+	if err := decodeHeader(msg, b); err != nil {
+		return nil, err
+	}
+	data := append([]byte(nil), b[diameterHeaderLen:msg.MessageLen]...)
+	avps, err := decodeAvps(d, data, diameterHeaderLen)
+	if err != nil {
+		return nil, err
+	}
+	msg.AVPs, msg.dict = avps, d
+	return msg, nil
+}
+
+// set msg's header fields from the start of b, checking that b holds the whole message
+func decodeHeader(msg *Diameter, b []byte) error {
+	if len(b) < diameterHeaderLen {
+		return fmt.Errorf("%w: %d bytes, shorter than the header", ErrMalformed, len(b))
+	}
+	*msg = Diameter{
+		Version:       b[0],
+		MessageLen:    uint24(b[1:]),
+		Flags:         b[4],
+		CommandCode:   uint24(b[5:]),
+		ApplicationID: binary.BigEndian.Uint32(b[8:]),
+		HopByHopID:    binary.BigEndian.Uint32(b[12:]),
+		EndToEndID:    binary.BigEndian.Uint32(b[16:]),
+	}
+	if msg.MessageLen < diameterHeaderLen || int(msg.MessageLen) > len(b) {
+		return fmt.Errorf("%w: message length %d, have %d bytes", ErrMalformed, msg.MessageLen, len(b))
+	}
+	return nil
+}
+
+// Decode a sequence of encoded AVPs, such as the data of a grouped AVP, see Decode.  b is not copied, the AVPs'
+// Data shares it.
+func DecodeAVPs(d *dictionary.Dictionary, b []byte) ([]*AVP, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	return decodeAvps(d, b, 0)
+}
+
+// decode the AVPs in b, which starts at offset in the message (for errors)
+func decodeAvps(d *dictionary.Dictionary, b []byte, offset int) ([]*AVP, error) {
+	var avps []*AVP
+	for len(b) > 0 {
+		avp := &AVP{}
+		padded, err := decodeAvpHeader(avp, b, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		t := dictionary.OctetString
+		if def := d.AVP(avp.VendorCode, avp.AttributeCode); def != nil {
+			t = def.Type
+			avp.AttributeName = def.Name
+		}
+		avp.AttributeFormat = t.String()
+		avp.decoder = newDecoder(t)
+		avp.dict = d
+		if t == dictionary.Grouped {
+			sub, err := decodeAvps(d, avp.Data, offset+int(avp.HeaderLen))
+			if err != nil {
+				return nil, err
+			}
+			avp.Grouped = sub
+		} else if avp.decodeErr = avp.decoder.decode(avp.Data); avp.decodeErr == nil {
+			avp.DecodedValue = avp.decoder.String()
+		}
+
+		avps = append(avps, avp)
+		b = b[padded:]
+		offset += padded
+	}
+	return avps, nil
+}
+
+// set the header fields and Data of avp from the AVP at the start of b; returns its length with padding
+func decodeAvpHeader(avp *AVP, b []byte, offset int) (int, error) {
+	if len(b) < avpHeaderLen {
+		return 0, fmt.Errorf("%w: %d bytes at offset %d, shorter than an AVP header", ErrMalformed, len(b), offset)
+	}
+	avp.AttributeCode = binary.BigEndian.Uint32(b)
+	avp.Flags = b[4]
+	avp.Len = uint24(b[5:])
+	avp.HeaderLen = avpHeaderLen
+	if avp.Flags&uint8(dictionary.FlagVendor) != 0 {
+		if len(b) < avpHeaderLen+avpVendorLen {
+			return 0, fmt.Errorf("%w: AVP %d at offset %d, truncated vendor id", ErrMalformed, avp.AttributeCode,
+				offset)
+		}
+		avp.VendorCode = binary.BigEndian.Uint32(b[8:])
+		avp.HeaderLen += avpVendorLen
+	}
+	if avp.Len < avp.HeaderLen || int(avp.Len) > len(b) {
+		return 0, fmt.Errorf("%w: AVP %d/%d at offset %d, length %d with %d bytes left", ErrMalformed,
+			avp.VendorCode, avp.AttributeCode, offset, avp.Len, len(b))
+	}
+	avp.Data = b[avp.HeaderLen:avp.Len:avp.Len]
+
+	// the last AVP of a message or group may come without its padding
+	padded := int(avp.Len+3) &^ 3
+	avp.unpadded = padded > len(b)
+	if avp.unpadded {
+		padded = len(b)
+	}
+	avp.Padding = uint32(padded) - avp.Len
+	return padded, nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
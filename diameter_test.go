@@ -0,0 +1,124 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	dia, err := Decode(nil, testPacketDiameterAccountingRequest271)
+	a.NilError(t, err)
+	a.Equal(t, dia.Version, uint8(1))
+	a.Equal(t, dia.Flags, FlagRequest|FlagProxiable)
+	a.Equal(t, dia.CommandCode, uint32(271))
+	a.Equal(t, dia.MessageLen, uint32(len(testPacketDiameterAccountingRequest271)))
+	a.Equal(t, len(dia.AVPs), 14)
+
+	sid := dia.AVPs[0]
+	a.Equal(t, sid.AttributeCode, uint32(263))
+	a.Equal(t, sid.AttributeName, "Session-Id")
+	a.Equal(t, sid.AttributeFormat, "UTF8String")
+	a.Equal(t, sid.HeaderLen, uint32(8))
+	a.Equal(t, (sid.Len+sid.Padding)%4, uint32(0))
+	a.Equal(t, sid.DecodedValue, string(sid.Data))
+
+	si := dia.AVPs[len(dia.AVPs)-1]
+	a.Equal(t, si.VendorCode, uint32(10415))
+	a.Equal(t, si.HeaderLen, uint32(12))
+	a.Equal(t, si.AttributeFormat, "Grouped")
+	_, grouped := si.GetDecoder().(*DiameterGrouped)
+	a.Assert(t, grouped)
+	a.Equal(t, len(si.Grouped), 3)
+
+	ai := NewAvpIndexer(dia)
+	a.Equal(t, ai.GetUint32(0, 485), uint32(1))
+	a.Equal(t, ai.FromGroup(10415, 874).GetIPAddress(10415, 1228).String(), "78.147.12.161")
+	a.Equal(t, ai.GetEnum(0, 480).Value, uint32(4))
+
+	// the input is copied
+	b := append([]byte(nil), testPacketDiameterAccountingRequest271...)
+	dia, err = Decode(nil, b)
+	a.NilError(t, err)
+	copy(b[20:], make([]byte, len(b)-20))
+	a.Equal(t, NewAvpIndexer(dia).GetUint32(0, 485), uint32(1))
+}
+
+func TestDecodeAVPs(t *testing.T) {
+	var b []byte
+	b = append(b, testAvp(t, 264, 0x40, 0, []byte("abcde"))...) // padded to 16
+	b = append(b, testAvp(t, 2, 0xc0, 10415, []byte{0x5e, 0x9e, 0xd9, 0x13})...)
+	b = append(b, testAvp(t, 999, 0, 0, []byte{1, 2})...)
+	b = append(b, testAvp(t, 55, 0x40, 0, []byte{1, 2, 3})...) // bad length for a Time
+	b = append(b, testAvp(t, 443, 0x40, 0, nil)...)            // empty group
+	b = append(b, testAvp(t, 1, 0x40, 0, []byte("u"))[:9]...)  // last AVP without its padding
+
+	avps, err := DecodeAVPs(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, len(avps), 6)
+	a.Equal(t, avps[0].DecodedValue, "abcde")
+	a.Equal(t, avps[0].Len, uint32(13))
+	a.Equal(t, avps[0].Padding, uint32(3))
+	a.Equal(t, avps[1].GetDecoder().(*DiameterUnsigned32).Get(), uint32(0x5e9ed913))
+	a.Equal(t, avps[1].DecodedValue, "1587468563")
+
+	// unknown AVPs are read as OctetString
+	a.Equal(t, avps[2].AttributeName, "")
+	a.Equal(t, avps[2].AttributeFormat, "OctetString")
+	a.Equal(t, avps[2].GetDecoder().(*DiameterOctetString).Get(), "\x01\x02")
+
+	// values that don't decode are left to the typed getters
+	a.Equal(t, avps[3].DecodedValue, "")
+	a.Equal(t, avps[3].GetDecoder().(*DiameterTime).Get(), time.Time{})
+	a.ErrorContains(t, avps[3].decodeErr, "data length 3")
+	a.NilError(t, avps[1].decodeErr)
+	a.Equal(t, len(avps[4].Grouped), 0)
+	a.Equal(t, avps[5].DecodedValue, "u")
+	a.Equal(t, avps[5].Padding, uint32(0))
+
+	// types come from the dictionary given
+	d := dictionary.New()
+	d.AddAVP(&dictionary.AVP{Name: "Test", Code: 999, Type: dictionary.Unsigned32})
+	avps, err = DecodeAVPs(d, b)
+	a.NilError(t, err)
+	a.Equal(t, avps[0].AttributeName, "")
+	a.Equal(t, avps[2].AttributeName, "Test")
+	a.Equal(t, avps[2].AttributeFormat, "Unsigned32")
+	a.Equal(t, avps[2].DecodedValue, "")
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	pkt := testPacketDiameterAccountingRequest271
+	avp := testAvp(t, 264, 0x40, 0, []byte("abcd"))
+	msg := func(avps []byte) []byte {
+		b, err := appendMessage(nil, 1, 0, 271, 3, 1, 2, avps)
+		a.NilError(t, err)
+		return b
+	}
+	for name, b := range map[string][]byte{
+		"short header":     pkt[:19],
+		"truncated":        pkt[:len(pkt)-1],
+		"short length":     append([]byte{1, 0, 0, 19}, pkt[4:20]...),
+		"short avp header": msg(avp[:6]),
+		"avp length":       msg(append([]byte{0, 0, 1, 8, 0x40, 0, 0, 7}, avp[8:]...)),
+		"avp past end":     msg(append([]byte{0, 0, 1, 8, 0x40, 0, 0, 13}, avp[8:]...)),
+		"vendor id":        msg([]byte{0, 0, 0, 1, 0x80, 0, 0, 8}),
+		"in group":         msg(testAvp(t, 443, 0x40, 0, avp[:10])),
+	} {
+		_, err := Decode(nil, b)
+		a.Assert(t, errors.Is(err, ErrMalformed), name)
+		_, err = DecodeLazy(nil, b)
+		a.Assert(t, errors.Is(err, ErrMalformed), name)
+	}
+
+	_, err := Decode(nil, msg(testAvp(t, 443, 0x40, 0, avp[:10])))
+	a.ErrorContains(t, err, "offset 28")
+}
+
+func testAvp(t *testing.T, code uint32, flags uint8, vendorId uint32, data []byte) []byte {
+	b, err := appendAvp(nil, code, flags, vendorId, data)
+	a.NilError(t, err)
+	return b
+}
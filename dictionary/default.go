@@ -0,0 +1,52 @@
+package dictionary
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sync"
+)
+
+// bundled dictionaries, loaded in file name order
+//
+//go:embed xml/*.xml
+var bundled embed.FS
+
+var defaultDict struct {
+	once sync.Once
+	d    *Dictionary
+}
+
+// Default returns the bundled dictionary: RFC 6733 base protocol, RFC 4006 credit control, the RFC 7155 AVPs used
+// by those, 3GPP TS 29.061 Gi/SGi, 29.212 Gx, 29.272 S6a/S6d and 32.299 charging AVPs.  The returned dictionary is
+// shared, Clone it before loading vendor dictionaries on top.
+func Default() *Dictionary {
+	defaultDict.once.Do(func() {
+		d, err := loadBundled()
+		if err != nil {
+			panic(err) // bundled files are covered by tests
+		}
+		defaultDict.d = d
+	})
+	return defaultDict.d
+}
+
+func loadBundled() (*Dictionary, error) {
+	d := New()
+	entries, err := bundled.ReadDir("xml")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		f, err := bundled.Open(path.Join("xml", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		err = d.LoadXML(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+	}
+	return d, nil
+}
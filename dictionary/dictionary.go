@@ -0,0 +1,477 @@
+// Package dictionary maps Diameter AVP names to codes, data types, flag rules, enumerations and grouped AVP
+// ABNF.  Dictionaries are read from XML or JSON files; Default() holds a bundled set covering RFC 6733, RFC 4006
+// and the 3GPP TS 29.061, 29.212, 29.272 and 32.299 AVPs commonly seen in practice.
+//
+//	d := dictionary.Default().Clone()
+//	err := d.LoadFile("/etc/diameter/acme.xml")
+//	rg, err := d.AVPByName("Rating-Group")
+//	fmt.Println(rg.Code, rg.Type, rg.ABNF())
+package dictionary
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownAVP is returned for a name or code the dictionary doesn't define.
+	ErrUnknownAVP = errors.New("unknown avp")
+	// ErrAmbiguousName is returned by AVPByName when more than one vendor defines an AVP of that name.
+	ErrAmbiguousName = errors.New("ambiguous avp name")
+)
+
+// Type is the data format of an AVP, RFC 6733 section 4.2 (basic) and 4.3 (derived)
+type Type uint8
+
+const (
+	Unknown Type = iota
+	OctetString
+	Integer32
+	Integer64
+	Unsigned32
+	Unsigned64
+	Float32
+	Float64
+	Grouped
+	Address
+	Time
+	UTF8String
+	DiameterIdentity
+	DiameterURI
+	Enumerated
+	IPFilterRule
+	QoSFilterRule
+)
+
+var typeNames = []string{
+	Unknown:          "Unknown",
+	OctetString:      "OctetString",
+	Integer32:        "Integer32",
+	Integer64:        "Integer64",
+	Unsigned32:       "Unsigned32",
+	Unsigned64:       "Unsigned64",
+	Float32:          "Float32",
+	Float64:          "Float64",
+	Grouped:          "Grouped",
+	Address:          "Address",
+	Time:             "Time",
+	UTF8String:       "UTF8String",
+	DiameterIdentity: "DiameterIdentity",
+	DiameterURI:      "DiameterURI",
+	Enumerated:       "Enumerated",
+	IPFilterRule:     "IPFilterRule",
+	QoSFilterRule:    "QoSFilterRule",
+}
+
+// other spellings found in dictionaries in the wild, gopacket's AttributeFormat among them
+var typeAliases = map[string]Type{
+	"DiamIdent":  DiameterIdentity,
+	"DiamURI":    DiameterURI,
+	"IPAddress":  Address,
+	"UTF8":       UTF8String,
+	"OctetStr":   OctetString,
+	"Enumerator": Enumerated,
+}
+
+func (t Type) String() string {
+	if int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return "Type(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ParseType returns the Type named s, accepting the RFC 6733 names and a few common abbreviations (DiamIdent etc).
+func ParseType(s string) (Type, error) {
+	for t, n := range typeNames {
+		if strings.EqualFold(n, s) && Type(t) != Unknown {
+			return Type(t), nil
+		}
+	}
+	if t, ok := typeAliases[s]; ok {
+		return t, nil
+	}
+	return Unknown, fmt.Errorf("unknown avp type %q", s)
+}
+
+// Flag is a set of AVP header flag bits, as laid out on the wire.
+type Flag uint8
+
+const (
+	FlagVendor    Flag = 0x80
+	FlagMandatory Flag = 0x40
+	FlagProtected Flag = 0x20
+)
+
+// letters used for the flags in dictionary files, in header bit order
+var flagLetters = []struct {
+	f Flag
+	c byte
+}{{FlagVendor, 'V'}, {FlagMandatory, 'M'}, {FlagProtected, 'P'}}
+
+// ParseFlags reads a flag set written as letters V, M and P, optionally separated by commas or spaces.
+func ParseFlags(s string) (Flag, error) {
+	var f Flag
+outer:
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ',' || c == ' ' {
+			continue
+		}
+		for _, fl := range flagLetters {
+			if c == fl.c || c == fl.c+'a'-'A' {
+				f |= fl.f
+				continue outer
+			}
+		}
+		return 0, fmt.Errorf("unknown avp flag %q in %q", c, s)
+	}
+	return f, nil
+}
+
+// format as comma separated letters, "V,M"
+func (f Flag) String() string {
+	var s []string
+	for _, fl := range flagLetters {
+		if f&fl.f != 0 {
+			s = append(s, string(fl.c))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// Enum is one named value of an Enumerated AVP.
+type Enum struct {
+	Name  string
+	Value uint32
+}
+
+// RuleKind is the position qualifier of an AVP within a grouped AVP's ABNF.
+type RuleKind uint8
+
+const (
+	Optional RuleKind = iota // [ AVP ]
+	Required                 // { AVP }
+	Fixed                    // < AVP >, required and at a fixed position
+)
+
+// Unbounded as a Rule's Max: the AVP may occur any number of times
+const Unbounded = -1
+
+// AnyAVP is the rule name standing for AVPs not otherwise listed, as in "*[ AVP ]"
+const AnyAVP = "AVP"
+
+// Rule is one line of a grouped AVP's ABNF: which AVP may occur, and how often.
+type Rule struct {
+	AVP  string // name of the AVP, or AnyAVP
+	Kind RuleKind
+	Min  int
+	Max  int // Unbounded for no upper limit
+}
+
+// AVP is the dictionary definition of one AVP.
+type AVP struct {
+	Name     string
+	Code     uint32
+	VendorID uint32
+	Type     Type
+
+	// header flag rules: flags that must, may and must not be set
+	Must    Flag
+	May     Flag
+	MustNot Flag
+
+	Enums []Enum // for Enumerated AVPs, in definition order
+	Rules []Rule // for Grouped AVPs, in ABNF order
+}
+
+// name of the given enumerated value
+func (a *AVP) EnumName(v uint32) (string, bool) {
+	for _, e := range a.Enums {
+		if e.Value == v {
+			return e.Name, true
+		}
+	}
+	return "", false
+}
+
+// value of the given enumeration name; names are matched case insensitively
+func (a *AVP) EnumValue(name string) (uint32, bool) {
+	for _, e := range a.Enums {
+		if strings.EqualFold(e.Name, name) {
+			return e.Value, true
+		}
+	}
+	return 0, false
+}
+
+// format the grouped AVP definition in RFC 6733 section 4.4 notation; empty for AVPs of other types
+//
+//	<Proxy-Info> ::= < AVP Header: 284 >
+//	                 { Proxy-Host }
+//	                 { Proxy-State }
+//	               * [ AVP ]
+func (a *AVP) ABNF() string {
+	if a.Type != Grouped {
+		return ""
+	}
+	var b strings.Builder
+	head := "<" + a.Name + "> ::= "
+	fmt.Fprintf(&b, "%s< AVP Header: %d", head, a.Code)
+	if a.VendorID != 0 {
+		fmt.Fprintf(&b, " %d", a.VendorID)
+	}
+	b.WriteString(" >")
+	pad := strings.Repeat(" ", len(head))
+	for _, r := range a.Rules {
+		q := r.qualifier()
+		b.WriteString("\n")
+		if len(q) < len(pad) {
+			b.WriteString(pad[len(q):])
+		}
+		b.WriteString(q)
+		switch r.Kind {
+		case Fixed:
+			b.WriteString("< " + r.AVP + " >")
+		case Required:
+			b.WriteString("{ " + r.AVP + " }")
+		default:
+			b.WriteString("[ " + r.AVP + " ]")
+		}
+	}
+	return b.String()
+}
+
+// ABNF repetition prefix, "" when the rule's bounds are the defaults for its kind
+func (r Rule) qualifier() string {
+	dfltMin := 1
+	if r.Kind == Optional {
+		dfltMin = 0
+	}
+	if r.Min == dfltMin && r.Max == 1 {
+		return ""
+	}
+	q := "*"
+	if r.Min != 0 {
+		q = strconv.Itoa(r.Min) + q
+	}
+	if r.Max != Unbounded {
+		q += strconv.Itoa(r.Max)
+	}
+	return q + " "
+}
+
+// Vendor is a vendor id (IANA enterprise number) and name.
+type Vendor struct {
+	ID   uint32
+	Name string
+}
+
+// Application is a Diameter application id and name.
+type Application struct {
+	ID   uint32
+	Name string
+}
+
+// Command is a Diameter command code, its name and the abbreviation used for its request/answer (CC for CCR/CCA).
+type Command struct {
+	Code  uint32
+	Name  string
+	Short string
+}
+
+type avpKey struct {
+	vendorId uint32
+	code     uint32
+}
+
+// Dictionary holds AVP, vendor, application and command definitions.  Loading is not safe for concurrent use; a
+// dictionary that is no longer modified may be read from any number of goroutines.
+type Dictionary struct {
+	vendors  map[uint32]*Vendor
+	apps     map[uint32]*Application
+	commands map[uint32]*Command
+	avps     map[avpKey]*AVP
+	byName   map[string][]*AVP
+}
+
+// New returns an empty dictionary.
+func New() *Dictionary {
+	return &Dictionary{
+		vendors:  make(map[uint32]*Vendor),
+		apps:     make(map[uint32]*Application),
+		commands: make(map[uint32]*Command),
+		avps:     make(map[avpKey]*AVP),
+		byName:   make(map[string][]*AVP),
+	}
+}
+
+// Clone returns a copy of d that can be extended without affecting d; definitions themselves are shared and must
+// not be modified.
+func (d *Dictionary) Clone() *Dictionary {
+	c := New()
+	for k, v := range d.vendors {
+		c.vendors[k] = v
+	}
+	for k, v := range d.apps {
+		c.apps[k] = v
+	}
+	for k, v := range d.commands {
+		c.commands[k] = v
+	}
+	for k, v := range d.avps {
+		c.avps[k] = v
+	}
+	for k, v := range d.byName {
+		c.byName[k] = append([]*AVP(nil), v...)
+	}
+	return c
+}
+
+// AddAVP adds a definition, replacing any previous one with the same vendor id and code.
+func (d *Dictionary) AddAVP(a *AVP) {
+	k := avpKey{a.VendorID, a.Code}
+	if old := d.avps[k]; old != nil {
+		d.removeName(old)
+	}
+	d.avps[k] = a
+	n := strings.ToLower(a.Name)
+	d.byName[n] = append(d.byName[n], a)
+}
+
+func (d *Dictionary) removeName(a *AVP) {
+	n := strings.ToLower(a.Name)
+	l := d.byName[n]
+	for i := range l {
+		if l[i] == a {
+			l = append(l[:i:i], l[i+1:]...)
+			break
+		}
+	}
+	if len(l) == 0 {
+		delete(d.byName, n)
+	} else {
+		d.byName[n] = l
+	}
+}
+
+// AddVendor adds or replaces a vendor.
+func (d *Dictionary) AddVendor(v *Vendor) {
+	d.vendors[v.ID] = v
+}
+
+// AddApplication adds or replaces an application.
+func (d *Dictionary) AddApplication(a *Application) {
+	d.apps[a.ID] = a
+}
+
+// AddCommand adds or replaces a command.
+func (d *Dictionary) AddCommand(c *Command) {
+	d.commands[c.Code] = c
+}
+
+// definition of the AVP with given vendor id and code, nil if unknown
+func (d *Dictionary) AVP(vendorId, code uint32) *AVP {
+	return d.avps[avpKey{vendorId, code}]
+}
+
+// definition of the AVP with given name, matched case insensitively.  Names defined by more than one vendor give
+// ErrAmbiguousName, use AVPsByName or AVPByVendorName for those.
+func (d *Dictionary) AVPByName(name string) (*AVP, error) {
+	l := d.byName[strings.ToLower(name)]
+	switch len(l) {
+	case 0:
+		return nil, fmt.Errorf("%w %q", ErrUnknownAVP, name)
+	case 1:
+		return l[0], nil
+	}
+	return nil, fmt.Errorf("%w %q, defined by vendors %s", ErrAmbiguousName, name, vendorList(l))
+}
+
+// definition of the AVP with given vendor id and name, matched case insensitively
+func (d *Dictionary) AVPByVendorName(vendorId uint32, name string) (*AVP, error) {
+	for _, a := range d.byName[strings.ToLower(name)] {
+		if a.VendorID == vendorId {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %d/%q", ErrUnknownAVP, vendorId, name)
+}
+
+// all definitions with given name, matched case insensitively, ordered by vendor id
+func (d *Dictionary) AVPsByName(name string) []*AVP {
+	l := append([]*AVP(nil), d.byName[strings.ToLower(name)]...)
+	sort.Slice(l, func(i, j int) bool { return l[i].VendorID < l[j].VendorID })
+	return l
+}
+
+// all AVP definitions, ordered by vendor id then code
+func (d *Dictionary) AVPs() []*AVP {
+	l := make([]*AVP, 0, len(d.avps))
+	for _, a := range d.avps {
+		l = append(l, a)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].VendorID != l[j].VendorID {
+			return l[i].VendorID < l[j].VendorID
+		}
+		return l[i].Code < l[j].Code
+	})
+	return l
+}
+
+func vendorList(l []*AVP) string {
+	ids := make([]string, len(l))
+	for i, a := range l {
+		ids[i] = strconv.FormatUint(uint64(a.VendorID), 10)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
+
+// vendor with given id, nil if unknown
+func (d *Dictionary) Vendor(id uint32) *Vendor {
+	return d.vendors[id]
+}
+
+// vendor with given name, matched case insensitively; nil if unknown
+func (d *Dictionary) VendorByName(name string) *Vendor {
+	for _, v := range d.vendors {
+		if strings.EqualFold(v.Name, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// application with given id, nil if unknown
+func (d *Dictionary) Application(id uint32) *Application {
+	return d.apps[id]
+}
+
+// command with given code, nil if unknown
+func (d *Dictionary) Command(code uint32) *Command {
+	return d.commands[code]
+}
+
+// Validate reports grouped AVP rules naming AVPs the dictionary doesn't define, and vendor ids with no Vendor
+// entry.  Definitions may refer to AVPs from other files, so run it once everything is loaded.
+func (d *Dictionary) Validate() error {
+	var errs []string
+	for _, a := range d.AVPs() {
+		if a.VendorID != 0 && d.vendors[a.VendorID] == nil {
+			errs = append(errs, fmt.Sprintf("%s: unknown vendor %d", a.Name, a.VendorID))
+		}
+		for _, r := range a.Rules {
+			if r.AVP != AnyAVP && len(d.byName[strings.ToLower(r.AVP)]) == 0 {
+				errs = append(errs, fmt.Sprintf("%s: rule for unknown avp %q", a.Name, r.AVP))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("invalid dictionary: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
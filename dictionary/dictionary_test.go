@@ -0,0 +1,101 @@
+package dictionary
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	d := Default()
+	a.NilError(t, d.Validate())
+
+	rg, err := d.AVPByName("rating-group")
+	a.NilError(t, err)
+	a.Equal(t, rg.Code, uint32(432))
+	a.Equal(t, rg.VendorID, uint32(0))
+	a.Equal(t, rg.Type, Unsigned32)
+	a.Equal(t, rg.Must, FlagMandatory)
+	a.Equal(t, rg.MustNot, FlagVendor)
+
+	sdc := d.AVP(10415, 2040)
+	a.Assert(t, sdc != nil)
+	a.Equal(t, sdc.Name, "Service-Data-Container")
+	a.Equal(t, sdc.Type, Grouped)
+	a.Equal(t, sdc.Must, FlagVendor|FlagMandatory)
+
+	art := d.AVP(0, 480)
+	n, ok := art.EnumName(3)
+	a.Assert(t, ok)
+	a.Equal(t, n, "INTERIM_RECORD")
+	v, ok := art.EnumValue("stop_record")
+	a.Assert(t, ok)
+	a.Equal(t, v, uint32(4))
+	_, ok = art.EnumName(9)
+	a.Assert(t, !ok)
+
+	a.Assert(t, d.AVP(10415, 9999) == nil)
+	_, err = d.AVPByName("No-Such-AVP")
+	a.Assert(t, errors.Is(err, ErrUnknownAVP))
+
+	a.Equal(t, d.Vendor(10415).Name, "3GPP")
+	a.Equal(t, d.VendorByName("3gpp").ID, uint32(10415))
+	a.Equal(t, d.Application(16777238).Name, "3GPP Gx")
+	a.Equal(t, d.Command(272).Short, "CC")
+
+	// every file contributed
+	for _, c := range [][2]uint32{{0, 263}, {0, 416}, {0, 30}, {10415, 2}, {10415, 1006}, {10415, 1430}, {10415, 874}} {
+		a.Assert(t, d.AVP(c[0], c[1]) != nil, c)
+	}
+}
+
+func TestAmbiguousName(t *testing.T) {
+	d := Default().Clone()
+	d.AddAVP(&AVP{Name: "Rating-Group", Code: 7, VendorID: 9, Type: Unsigned32})
+
+	_, err := d.AVPByName("Rating-Group")
+	a.Assert(t, errors.Is(err, ErrAmbiguousName))
+	l := d.AVPsByName("Rating-Group")
+	a.Equal(t, len(l), 2)
+	a.Equal(t, l[0].Code, uint32(432))
+	rg, err := d.AVPByVendorName(9, "Rating-Group")
+	a.NilError(t, err)
+	a.Equal(t, rg.Code, uint32(7))
+
+	// replacing a definition drops the old name; the shared default is untouched
+	d.AddAVP(&AVP{Name: "Acme-Group", Code: 7, VendorID: 9, Type: Unsigned32})
+	_, err = d.AVPByName("Rating-Group")
+	a.NilError(t, err)
+	a.Assert(t, Default().AVP(9, 7) == nil)
+}
+
+func TestABNF(t *testing.T) {
+	d := Default()
+	a.Equal(t, d.AVP(0, 284).ABNF(), `<Proxy-Info> ::= < AVP Header: 284 >
+                 { Proxy-Host }
+                 { Proxy-State }
+               * [ AVP ]`)
+	a.Equal(t, d.AVP(10415, 1429).ABNF(), `<APN-Configuration-Profile> ::= < AVP Header: 1429 10415 >
+                                { Context-Identifier }
+                                { All-APN-Configurations-Included-Indicator }
+                             1* { APN-Configuration }
+                              * [ AVP ]`)
+	a.Equal(t, d.AVP(0, 432).ABNF(), "")
+}
+
+func TestParseTypeAndFlags(t *testing.T) {
+	for s, want := range map[string]Type{"Unsigned32": Unsigned32, "DiamIdent": DiameterIdentity, "address": Address} {
+		got, err := ParseType(s)
+		a.NilError(t, err)
+		a.Equal(t, got, want)
+	}
+	_, err := ParseType("Unknown")
+	a.Assert(t, err != nil)
+
+	f, err := ParseFlags("V, m")
+	a.NilError(t, err)
+	a.Equal(t, f, FlagVendor|FlagMandatory)
+	a.Equal(t, f.String(), "V,M")
+	_, err = ParseFlags("X")
+	a.Assert(t, err != nil)
+}
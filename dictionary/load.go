@@ -0,0 +1,251 @@
+package dictionary
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Dictionary files.  XML and JSON carry the same content:
+//
+//	<dictionary>
+//	  <vendor id="10415" name="3GPP"/>
+//	  <application id="4" name="Diameter Credit Control"/>
+//	  <command code="272" name="Credit-Control" short="CC"/>
+//	  <avp name="CC-Request-Type" code="416" type="Enumerated" must="M" may="P" must-not="V">
+//	    <enum code="1" name="INITIAL_REQUEST"/>
+//	  </avp>
+//	  <avp name="Subscription-Id" code="443" type="Grouped" must="M" may="P" must-not="V">
+//	    <rule avp="Subscription-Id-Type" kind="required"/>
+//	    <rule avp="Subscription-Id-Data" kind="required"/>
+//	  </avp>
+//	  <avp name="Node-Id" code="2064" vendor-id="10415" type="UTF8String" must="V,M"/>
+//	</dictionary>
+//
+//	{"vendors": [{"id": 10415, "name": "3GPP"}],
+//	 "avps": [{"name": "Subscription-Id", "code": 443, "type": "Grouped", "must": "M",
+//	           "rules": [{"avp": "Subscription-Id-Type", "kind": "required"}, ...]}]}
+//
+// A rule's kind is fixed, required or optional (the default); min defaults to 1 for fixed and required rules and 0
+// for optional ones, max defaults to 1 and may be "*" for no limit.
+
+type fileDictionary struct {
+	XMLName      xml.Name          `xml:"dictionary" json:"-"`
+	Vendors      []fileVendor      `xml:"vendor" json:"vendors"`
+	Applications []fileApplication `xml:"application" json:"applications"`
+	Commands     []fileCommand     `xml:"command" json:"commands"`
+	AVPs         []fileAVP         `xml:"avp" json:"avps"`
+}
+
+type fileVendor struct {
+	ID   uint32 `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type fileApplication struct {
+	ID   uint32 `xml:"id,attr" json:"id"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type fileCommand struct {
+	Code  uint32 `xml:"code,attr" json:"code"`
+	Name  string `xml:"name,attr" json:"name"`
+	Short string `xml:"short,attr" json:"short"`
+}
+
+type fileAVP struct {
+	Name     string     `xml:"name,attr" json:"name"`
+	Code     uint32     `xml:"code,attr" json:"code"`
+	VendorID uint32     `xml:"vendor-id,attr" json:"vendorId"`
+	Type     string     `xml:"type,attr" json:"type"`
+	Must     string     `xml:"must,attr" json:"must"`
+	May      string     `xml:"may,attr" json:"may"`
+	MustNot  string     `xml:"must-not,attr" json:"mustNot"`
+	Enums    []fileEnum `xml:"enum" json:"enums"`
+	Rules    []fileRule `xml:"rule" json:"rules"`
+}
+
+type fileEnum struct {
+	Code uint32 `xml:"code,attr" json:"code"`
+	Name string `xml:"name,attr" json:"name"`
+}
+
+type fileRule struct {
+	AVP  string   `xml:"avp,attr" json:"avp"`
+	Kind string   `xml:"kind,attr" json:"kind"`
+	Min  intOrStr `xml:"min,attr" json:"min"`
+	Max  intOrStr `xml:"max,attr" json:"max"`
+}
+
+// rule bounds are written as strings in XML; JSON files may use either numbers or strings
+type intOrStr string
+
+func (v *intOrStr) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = intOrStr(s)
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("rule bound must be a number or string: %s", b)
+	}
+	*v = intOrStr(strconv.Itoa(n))
+	return nil
+}
+
+// Load reads a dictionary file in either format, telling XML from JSON by its first character.  Definitions are
+// added to d, replacing earlier ones with the same code.
+func (d *Dictionary) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		c, err := br.Peek(1)
+		if err != nil {
+			return fmt.Errorf("dictionary: %w", err)
+		}
+		switch c[0] {
+		case ' ', '\t', '\r', '\n', 0xef, 0xbb, 0xbf: // whitespace, utf-8 byte order mark
+			br.Discard(1)
+			continue
+		case '{':
+			return d.LoadJSON(br)
+		}
+		return d.LoadXML(br)
+	}
+}
+
+// LoadFile reads a dictionary file, see Load.
+func (d *Dictionary) LoadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := d.Load(f); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadXML reads a dictionary in XML format.
+func (d *Dictionary) LoadXML(r io.Reader) error {
+	var fd fileDictionary
+	if err := xml.NewDecoder(r).Decode(&fd); err != nil {
+		return fmt.Errorf("dictionary: %w", err)
+	}
+	return d.add(&fd)
+}
+
+// LoadJSON reads a dictionary in JSON format.
+func (d *Dictionary) LoadJSON(r io.Reader) error {
+	var fd fileDictionary
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fd); err != nil {
+		return fmt.Errorf("dictionary: %w", err)
+	}
+	return d.add(&fd)
+}
+
+// convert the whole file before adding anything, so that a bad file leaves d unchanged
+func (d *Dictionary) add(fd *fileDictionary) error {
+	avps := make([]*AVP, len(fd.AVPs))
+	for i := range fd.AVPs {
+		a, err := fd.AVPs[i].convert()
+		if err != nil {
+			return fmt.Errorf("dictionary: avp %q: %w", fd.AVPs[i].Name, err)
+		}
+		avps[i] = a
+	}
+	for _, v := range fd.Vendors {
+		d.AddVendor(&Vendor{ID: v.ID, Name: v.Name})
+	}
+	for _, a := range fd.Applications {
+		d.AddApplication(&Application{ID: a.ID, Name: a.Name})
+	}
+	for _, c := range fd.Commands {
+		d.AddCommand(&Command{Code: c.Code, Name: c.Name, Short: c.Short})
+	}
+	for _, a := range avps {
+		d.AddAVP(a)
+	}
+	return nil
+}
+
+func (fa *fileAVP) convert() (*AVP, error) {
+	if fa.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	t, err := ParseType(fa.Type)
+	if err != nil {
+		return nil, err
+	}
+	a := &AVP{Name: fa.Name, Code: fa.Code, VendorID: fa.VendorID, Type: t}
+	if a.Must, err = ParseFlags(fa.Must); err != nil {
+		return nil, err
+	}
+	if a.May, err = ParseFlags(fa.May); err != nil {
+		return nil, err
+	}
+	if a.MustNot, err = ParseFlags(fa.MustNot); err != nil {
+		return nil, err
+	}
+	if a.Must&a.MustNot != 0 {
+		return nil, fmt.Errorf("flags %s both must and must not be set", a.Must&a.MustNot)
+	}
+	if len(fa.Enums) > 0 && t != Enumerated {
+		return nil, fmt.Errorf("enum values on %s avp", t)
+	}
+	if len(fa.Rules) > 0 && t != Grouped {
+		return nil, fmt.Errorf("rules on %s avp", t)
+	}
+	for _, e := range fa.Enums {
+		a.Enums = append(a.Enums, Enum{Name: e.Name, Value: e.Code})
+	}
+	for _, fr := range fa.Rules {
+		r, err := fr.convert()
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", fr.AVP, err)
+		}
+		a.Rules = append(a.Rules, r)
+	}
+	return a, nil
+}
+
+func (fr *fileRule) convert() (Rule, error) {
+	r := Rule{AVP: fr.AVP, Max: 1}
+	switch strings.ToLower(fr.Kind) {
+	case "", "optional":
+		r.Kind = Optional
+	case "required":
+		r.Kind, r.Min = Required, 1
+	case "fixed":
+		r.Kind, r.Min = Fixed, 1
+	default:
+		return r, fmt.Errorf("unknown rule kind %q", fr.Kind)
+	}
+	if r.AVP == "" {
+		return r, fmt.Errorf("missing avp name")
+	}
+	var err error
+	if fr.Min != "" {
+		if r.Min, err = strconv.Atoi(string(fr.Min)); err != nil || r.Min < 0 {
+			return r, fmt.Errorf("bad min %q", fr.Min)
+		}
+	}
+	switch fr.Max {
+	case "":
+	case "*":
+		r.Max = Unbounded
+	default:
+		if r.Max, err = strconv.Atoi(string(fr.Max)); err != nil || r.Max < 1 || r.Max < r.Min {
+			return r, fmt.Errorf("bad max %q", fr.Max)
+		}
+	}
+	return r, nil
+}
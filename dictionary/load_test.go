@@ -0,0 +1,92 @@
+package dictionary
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const vendorXML = `<?xml version="1.0"?>
+<dictionary>
+  <vendor id="99999" name="Acme"/>
+  <avp name="Acme-Plan" code="1" vendor-id="99999" type="Enumerated" must="V" may="M">
+    <enum code="0" name="BASIC"/>
+    <enum code="1" name="GOLD"/>
+  </avp>
+  <avp name="Acme-Usage" code="2" vendor-id="99999" type="Grouped" must="V">
+    <rule avp="Acme-Plan" kind="fixed"/>
+    <rule avp="Rating-Group" kind="required" max="*"/>
+    <rule avp="CC-Total-Octets" min="1" max="4"/>
+  </avp>
+</dictionary>`
+
+const vendorJSON = `
+{"vendors": [{"id": 99999, "name": "Acme"}],
+ "avps": [
+  {"name": "Acme-Plan", "code": 1, "vendorId": 99999, "type": "Enumerated", "must": "V", "may": "M",
+   "enums": [{"code": 0, "name": "BASIC"}, {"code": 1, "name": "GOLD"}]},
+  {"name": "Acme-Usage", "code": 2, "vendorId": 99999, "type": "Grouped", "must": "V",
+   "rules": [{"avp": "Acme-Plan", "kind": "fixed"}, {"avp": "Rating-Group", "kind": "required", "max": "*"},
+             {"avp": "CC-Total-Octets", "min": 1, "max": 4}]}]}`
+
+func TestLoadVendor(t *testing.T) {
+	for _, src := range []string{vendorXML, vendorJSON} {
+		d := Default().Clone()
+		a.NilError(t, d.Load(strings.NewReader(src)))
+		a.NilError(t, d.Validate())
+
+		plan, err := d.AVPByName("Acme-Plan")
+		a.NilError(t, err)
+		a.Equal(t, plan.Type, Enumerated)
+		a.Equal(t, plan.Must, FlagVendor)
+		a.Equal(t, plan.May, FlagMandatory)
+		a.DeepEqual(t, plan.Enums, []Enum{{"BASIC", 0}, {"GOLD", 1}})
+
+		usage := d.AVP(99999, 2)
+		a.DeepEqual(t, usage.Rules, []Rule{
+			{AVP: "Acme-Plan", Kind: Fixed, Min: 1, Max: 1},
+			{AVP: "Rating-Group", Kind: Required, Min: 1, Max: Unbounded},
+			{AVP: "CC-Total-Octets", Kind: Optional, Min: 1, Max: 4},
+		})
+		a.Equal(t, usage.ABNF(), `<Acme-Usage> ::= < AVP Header: 2 99999 >
+                 < Acme-Plan >
+              1* { Rating-Group }
+             1*4 [ CC-Total-Octets ]`)
+		a.Equal(t, d.Vendor(99999).Name, "Acme")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "acme.xml")
+	a.NilError(t, os.WriteFile(fn, []byte(vendorXML), 0644))
+	d := New()
+	a.NilError(t, d.LoadFile(fn))
+	a.Assert(t, d.AVP(99999, 1) != nil)
+	// rules refer to avps from the base dictionaries, not loaded here
+	a.ErrorContains(t, d.Validate(), `rule for unknown avp "Rating-Group"`)
+
+	err := d.LoadFile(filepath.Join(t.TempDir(), "missing.xml"))
+	a.Assert(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestLoadErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		`<dictionary><avp name="X" code="1" type="Bogus"/></dictionary>`:                                    `unknown avp type "Bogus"`,
+		`<dictionary><avp name="X" code="1" type="Unsigned32" must="M" must-not="M"/></dictionary>`:         "both must and must not",
+		`<dictionary><avp name="X" code="1" type="Unsigned32"><enum code="1" name="A"/></avp></dictionary>`: "enum values on Unsigned32",
+		`<dictionary><avp name="X" code="1" type="Grouped"><rule avp="Y" kind="maybe"/></avp></dictionary>`: `unknown rule kind "maybe"`,
+		`<dictionary><avp name="X" code="1" type="Grouped"><rule avp="Y" max="0"/></avp></dictionary>`:      `bad max "0"`,
+		`{"avps": [{"name": "X", "code": 1, "type": "Unsigned32", "color": "red"}]}`:                        `unknown field "color"`,
+		`<dictionary><avp name="X"`: "dictionary:",
+	} {
+		d := New()
+		err := d.Load(strings.NewReader(src))
+		a.ErrorContains(t, err, msg)
+		// a bad file adds nothing
+		a.Equal(t, len(d.AVPs()), 0)
+	}
+}
@@ -0,0 +1,319 @@
+package avpindexer
+
+import (
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"reflect"
+)
+
+// Editing messages with path addressed operations, for DRA-style tools rewriting messages in flight:
+//
+//	m, err := NewEditableMessage(nil, dia)
+//	n, err := m.Set("Origin-Host", "dra.example.com")
+//	n, err = m.Delete("Proxy-Info")
+//	err = m.Append("Route-Record", "dra.example.com")
+//	n, err = m.Set("Multiple-Services-Credit-Control/Rating-Group", uint32(200))   every MSCC
+//	n, err = m.Insert(InsertAfter, "Session-Id", "Destination-Host", "ocs2.example.com")
+//	b, err := m.Bytes()
+//
+// Paths are as for Query or QueryName (ids or names, wildcards and '**' allowed) and every matching AVP is edited,
+// except that Set and Delete leave matches within a matching group to the edit of that group.  Values are encoded as
+// for MessageBuilder.  The AVP tree is edited in place and only encoded by Bytes: after each operation lengths,
+// decoded values and Indexer() are consistent with the edit, the Data of grouped AVPs is not (Encode doesn't use
+// it).  AVP pointers taken before an edit stay valid, an AVP changed by Set in place; those removed, or replaced
+// along with their group, are no longer part of the message.  An operation that fails leaves the message as it was.
+
+// InsertPosition says where Insert puts new AVPs relative to the AVPs matching its path.
+type InsertPosition int
+
+const (
+	InsertBefore InsertPosition = iota // before each matching AVP
+	InsertAfter                        // after each matching AVP
+	InsertAppend                       // as the last sub-AVP of each matching grouped AVP
+)
+
+// EditableMessage is a message with editing operations that keep its indexer up to date, see NewEditableMessage.
+type EditableMessage struct {
+	d    *Diameter
+	ai   AvpIndexer
+	dict *dictionary.Dictionary
+}
+
+// Start editing a copy of dia, with AVP names, types and flags from d (dictionary.Default() if nil); dia itself is
+// left alone.
+func NewEditableMessage(d *dictionary.Dictionary, dia *Diameter) (*EditableMessage, error) {
+	b, err := Encode(dia)
+	if err != nil {
+		return nil, err
+	}
+	work, err := Decode(d, b)
+	if err != nil {
+		return nil, err
+	}
+	return &EditableMessage{d: work, ai: NewAvpIndexer(work), dict: d}, nil
+}
+
+// The message as edited so far.  Changes made to it directly are kept, and seen by the next operation.
+func (m *EditableMessage) Diameter() *Diameter {
+	return m.d
+}
+
+// Indexer over the message as edited so far.
+func (m *EditableMessage) Indexer() AvpIndexer {
+	return m.ai
+}
+
+// Encoded message, see Encode; the edits made so far are encoded here, not as they are made.
+func (m *EditableMessage) Bytes() ([]byte, error) {
+	return Encode(m.d)
+}
+
+// Replace the value of every AVP matching path with v, keeping its flags; a tagged struct replaces the sub-AVPs of
+// grouped AVPs.  Matches within a matching group are replaced along with it, not set themselves.  Returns the
+// number of AVPs changed.
+func (m *EditableMessage) Set(path string, v interface{}) (int, error) {
+	p, err := parseTagPath(m.dictionary(), path)
+	if err != nil {
+		return 0, fmt.Errorf("Set: %w", err)
+	}
+	matches := m.outermost(p)
+
+	// all replacements are made before any is applied, so that a value that fails to encode changes nothing
+	replacements := make([]*AVP, 0, len(matches))
+	for _, avp := range matches {
+		id := avpId{vendorId: avp.VendorCode, attrId: avp.AttributeCode}
+		avps, err := m.build(id, path, v)
+		if err != nil {
+			return 0, fmt.Errorf("Set: %w", err)
+		}
+		if len(avps) != 1 {
+			return 0, fmt.Errorf("Set: %s: value makes %d AVPs, not 1", path, len(avps))
+		}
+		avps[0].Flags = avp.Flags
+		replacements = append(replacements, avps[0])
+	}
+	for i, avp := range matches {
+		*avp = *replacements[i]
+	}
+	m.edited()
+	return len(matches), nil
+}
+
+// Insert AVPs made from v (one, or one per element of a slice) with given name or vendorId/attrId at pos relative to
+// every AVP matching path; for InsertAppend an empty path means the message itself.  Returns the number of places
+// AVPs were inserted at.
+func (m *EditableMessage) Insert(pos InsertPosition, path, avp string, v interface{}) (int, error) {
+	var p Path
+	var err error
+	if path != "" || pos != InsertAppend {
+		if p, err = parseTagPath(m.dictionary(), path); err != nil {
+			return 0, fmt.Errorf("Insert: %w", err)
+		}
+	}
+	ids, err := marshalPath(m.dictionary(), avp)
+	if err != nil {
+		return 0, fmt.Errorf("Insert: %w", err)
+	}
+	if len(ids) != 1 {
+		return 0, fmt.Errorf("Insert: %q is not a single AVP", avp)
+	}
+
+	if p.leaf == nil {
+		avps, err := m.build(ids[0], avp, v)
+		if err != nil {
+			return 0, fmt.Errorf("Insert: %w", err)
+		}
+		m.d.AVPs = append(m.d.AVPs, avps...)
+		m.edited()
+		return 1, nil
+	}
+
+	// as for Set, everything is built and checked before the message is changed
+	var matches []*AVP
+	var inserts [][]*AVP
+	m.index().QueryPath(p).VisitAvp(func(match *AVP) {
+		if err != nil {
+			return
+		}
+		if pos == InsertAppend && !isGrouped(m.dictionary(), match) {
+			err = fmt.Errorf("%s matches %d/%d, not a grouped AVP", path, match.VendorCode, match.AttributeCode)
+			return
+		}
+		var avps []*AVP
+		if avps, err = m.build(ids[0], avp, v); err != nil {
+			return
+		}
+		matches, inserts = append(matches, match), append(inserts, avps)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Insert: %w", err)
+	}
+	var lists map[*AVP]*[]*AVP
+	if pos != InsertAppend && len(matches) > 0 {
+		lists = avpLists(m.d)
+	}
+	for j, match := range matches {
+		avps := inserts[j]
+		switch pos {
+		case InsertBefore, InsertAfter:
+			list := lists[match]
+			i := indexOf(*list, match)
+			if pos == InsertAfter {
+				i++
+			}
+			*list = append((*list)[:i], append(avps, (*list)[i:]...)...)
+		case InsertAppend:
+			match.Grouped = append(match.Grouped, avps...)
+		}
+	}
+	m.edited()
+	return len(matches), nil
+}
+
+// Append AVPs made from v with given name or vendorId/attrId to the message, see Insert.
+func (m *EditableMessage) Append(avp string, v interface{}) error {
+	_, err := m.Insert(InsertAppend, "", avp, v)
+	return err
+}
+
+// Remove every AVP matching path, with its sub-AVPs.  Returns the number of AVPs removed from the message; matches
+// within a matching group go with it and aren't counted.
+func (m *EditableMessage) Delete(path string) (int, error) {
+	p, err := parseTagPath(m.dictionary(), path)
+	if err != nil {
+		return 0, fmt.Errorf("Delete: %w", err)
+	}
+	matches := m.outermost(p)
+	if len(matches) > 0 {
+		lists := avpLists(m.d)
+		for _, avp := range matches {
+			list := lists[avp]
+			i := indexOf(*list, avp)
+			*list = append((*list)[:i], (*list)[i+1:]...)
+		}
+	}
+	m.edited()
+	return len(matches), nil
+}
+
+func (m *EditableMessage) dictionary() *dictionary.Dictionary {
+	if m.dict == nil {
+		return dictionary.Default()
+	}
+	return m.dict
+}
+
+// indexer over the message as it is now, changes made directly to Diameter() included
+func (m *EditableMessage) index() AvpIndexer {
+	return NewAvpIndexer(m.d)
+}
+
+// bring lengths and the indexer up to date after the AVP tree was changed
+func (m *EditableMessage) edited() {
+	m.d.MessageLen = diameterHeaderLen + relength(m.d.AVPs)
+	m.ai = NewAvpIndexer(m.d)
+}
+
+// set Len and Padding of avps and their sub-AVPs as Encode will encode them; returns their total length
+func relength(avps []*AVP) uint32 {
+	var l uint32
+	for i, avp := range avps {
+		avp.HeaderLen = avpHeaderLen
+		if avp.Flags&uint8(dictionary.FlagVendor) != 0 {
+			avp.HeaderLen += avpVendorLen
+		}
+		if _, grouped := avp.GetDecoder().(*DiameterGrouped); grouped || avp.Grouped != nil {
+			avp.Len = avp.HeaderLen + relength(avp.Grouped)
+		} else {
+			avp.Len = avp.HeaderLen + uint32(len(avp.Data))
+		}
+		avp.Padding = (4 - avp.Len%4) % 4
+		if avp.unpadded && i == len(avps)-1 {
+			avp.Padding = 0
+		}
+		l += avp.Len + avp.Padding
+	}
+	return l
+}
+
+// decoded AVPs made from v with given id, as MessageBuilder.AVP adds them
+func (m *EditableMessage) build(id avpId, name string, v interface{}) ([]*AVP, error) {
+	if v == nil {
+		return nil, fmt.Errorf("%s: %w: nil value", name, ErrAvpEncode)
+	}
+	var n avpNode
+	if err := marshalField(m.dictionary(), &n, id, reflect.ValueOf(v), name); err != nil {
+		return nil, err
+	}
+	b, err := n.appendChildren(nil)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeAVPs(m.dictionary(), b)
+}
+
+// AVPs matching p that aren't within another match, in message order
+func (m *EditableMessage) outermost(p Path) []*AVP {
+	var matches []*AVP
+	m.index().QueryPath(p).VisitAvp(func(avp *AVP) {
+		matches = append(matches, avp)
+	})
+	if len(matches) < 2 {
+		return matches
+	}
+	matched := make(map[*AVP]bool, len(matches))
+	for _, avp := range matches {
+		matched[avp] = true
+	}
+	parents := avpParents(m.d)
+	outer := matches[:0]
+	for _, avp := range matches {
+		nested := false
+		for g := parents[avp]; g != nil && !nested; g = parents[g] {
+			nested = matched[g]
+		}
+		if !nested {
+			outer = append(outer, avp)
+		}
+	}
+	return outer
+}
+
+// the grouped AVP each sub-AVP of d is in
+func avpParents(d *Diameter) map[*AVP]*AVP {
+	parents := make(map[*AVP]*AVP)
+	var walk func(parent *AVP)
+	walk = func(parent *AVP) {
+		for _, avp := range parent.Grouped {
+			parents[avp] = parent
+			walk(avp)
+		}
+	}
+	for _, avp := range d.AVPs {
+		walk(avp)
+	}
+	return parents
+}
+
+// the slice holding each AVP of d: d.AVPs or its parent's Grouped
+func avpLists(d *Diameter) map[*AVP]*[]*AVP {
+	lists := make(map[*AVP]*[]*AVP)
+	var walk func(list *[]*AVP)
+	walk = func(list *[]*AVP) {
+		for _, avp := range *list {
+			lists[avp] = list
+			walk(&avp.Grouped)
+		}
+	}
+	walk(&d.AVPs)
+	return lists
+}
+
+func indexOf(list []*AVP, avp *AVP) int {
+	for i, a := range list {
+		if a == avp {
+			return i
+		}
+	}
+	return -1
+}
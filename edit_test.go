@@ -0,0 +1,168 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestEditableMessage(t *testing.T) {
+	originalHost := NewAvpIndexer(d).GetUTF8String(0, 264)
+	m, err := NewEditableMessage(nil, d)
+	a.NilError(t, err)
+
+	n, err := m.Set("Origin-Host", "dra.example.com")
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	sessionId := m.Indexer().First(0, 263)
+	a.Equal(t, m.Indexer().GetUTF8String(0, 264), "dra.example.com")
+	a.Equal(t, m.Diameter().AVPs[1].Flags, d.AVPs[1].Flags)
+	a.Equal(t, NewAvpIndexer(d).GetUTF8String(0, 264), originalHost)
+
+	n, err = m.Set("**/Service-Data-Container/Rating-Group", uint32(77))
+	a.NilError(t, err)
+	a.Equal(t, n, 2)
+	a.DeepEqual(t, m.Indexer().GetAllUint32(0, 432), []uint32{77, 77})
+
+	n, err = m.Delete("Service-Information/Subscription-Id")
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.Assert(t, !m.Indexer().Exists(0, 444))
+
+	a.NilError(t, m.Append("Route-Record", "dra.example.com"))
+	last := m.Diameter().AVPs[len(m.Diameter().AVPs)-1]
+	a.Equal(t, last.AttributeCode, uint32(282))
+	a.Equal(t, m.Indexer().GetUTF8String(0, 282), "dra.example.com")
+
+	n, err = m.Insert(InsertAfter, "Session-Id", "Destination-Host", "ocs2.example.com")
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.Equal(t, m.Diameter().AVPs[1].AttributeCode, uint32(293))
+	a.DeepEqual(t, m.Indexer().GetAllUTF8String(0, 293), []string{"ocs2.example.com",
+		NewAvpIndexer(d).GetUTF8String(0, 293)})
+
+	n, err = m.Insert(InsertAppend, "**/Service-Data-Container", "Service-Identifier", uint32(5))
+	a.NilError(t, err)
+	a.Equal(t, n, 2)
+	for _, sdc := range m.Indexer().Query("**/10415/2040").Groups() {
+		all := sdc.GetAllUint32(0, 439)
+		a.Equal(t, all[len(all)-1], uint32(5))
+	}
+	a.Equal(t, len(m.Indexer().Query("**/10415/2040").First().Grouped), 14)
+
+	proxy := struct {
+		Host  string `avp:"Proxy-Host"`
+		State []byte `avp:"Proxy-State"`
+	}{"relay.example.com", []byte{1, 2, 3}}
+	n, err = m.Insert(InsertBefore, "Event-Timestamp", "Proxy-Info", proxy)
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.Equal(t, m.Indexer().FromGroup(0, 284).GetUTF8String(0, 280), "relay.example.com")
+	n, err = m.Delete("Proxy-Info")
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+
+	// edited in place: AVPs the edits didn't touch are the same ones
+	a.Equal(t, m.Indexer().First(0, 263), sessionId)
+	ps := m.Indexer().First(10415, 874)
+	_, err = m.Set("**/Service-Data-Container/Time-Usage", uint32(9))
+	a.NilError(t, err)
+	a.Equal(t, m.Indexer().First(10415, 874), ps)
+	a.Equal(t, NewAvpIndexer(d).GetUint32(10415, 2045), uint32(241))
+
+	b, err := m.Bytes()
+	a.NilError(t, err)
+	back, err := Decode(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, back.MessageLen, uint32(len(b)))
+	a.Equal(t, m.Diameter().MessageLen, back.MessageLen)
+	a.Equal(t, ps.Len, NewAvpIndexer(back).First(10415, 874).Len)
+	a.Equal(t, NewAvpIndexer(back).GetUTF8String(0, 264), "dra.example.com")
+	a.Assert(t, !NewAvpIndexer(back).Exists(0, 284))
+
+	// nothing matching is no error
+	n, err = m.Delete("0/999")
+	a.NilError(t, err)
+	a.Equal(t, n, 0)
+}
+
+func TestEditableMessageErrors(t *testing.T) {
+	m, err := NewEditableMessage(nil, d)
+	a.NilError(t, err)
+	before, err := m.Bytes()
+	a.NilError(t, err)
+
+	_, err = m.Set("No-Such-AVP", 1)
+	a.ErrorContains(t, err, "Set:")
+	_, err = m.Set("Session-Id", []string{"a", "b"})
+	a.ErrorContains(t, err, "not 1")
+	_, err = m.Set("Rating-Group", "x")
+	a.ErrorContains(t, err, "can't encode string")
+	_, err = m.Insert(InsertAppend, "Session-Id", "User-Name", "x")
+	a.ErrorContains(t, err, "not a grouped AVP")
+	_, err = m.Insert(InsertAfter, "Session-Id", "**/User-Name", "x")
+	a.ErrorContains(t, err, "wildcards")
+
+	// failed edits leave the message alone
+	after, err := m.Bytes()
+	a.NilError(t, err)
+	a.DeepEqual(t, after, before)
+}
+
+func TestEditableMessageNested(t *testing.T) {
+	// matches within another match are edited along with it, and not counted
+	m, err := NewEditableMessage(nil, d)
+	a.NilError(t, err)
+	a.Equal(t, m.Indexer().Count(10415, Wildcard), 39)
+	n, err := m.Delete("10415/*")
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.Equal(t, m.Indexer().Count(10415, Wildcard), 0)
+
+	m, err = NewEditableMessage(nil, d)
+	a.NilError(t, err)
+	n, err = m.Delete("10415/874/**/10415/*")
+	a.NilError(t, err)
+	a.Equal(t, n, 21)
+	a.Equal(t, m.Indexer().Count(0, 432), 0)
+
+	nested, err := NewMessage(272, 4, true).
+		Group(0, 456, func(g *GroupBuilder) {
+			g.AVP(0, 432, uint32(1))
+			g.Group(0, 456, func(g *GroupBuilder) { g.AVP(0, 432, uint32(2)) })
+		}).
+		Diameter()
+	a.NilError(t, err)
+	m, err = NewEditableMessage(nil, nested)
+	a.NilError(t, err)
+	n, err = m.Set("**/0/456", struct {
+		RatingGroup uint32 `avp:"0/432"`
+	}{9})
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.DeepEqual(t, m.Indexer().GetAllUint32(0, 432), []uint32{9})
+	b, err := m.Bytes()
+	a.NilError(t, err)
+	a.Equal(t, m.Diameter().MessageLen, uint32(len(b)))
+}
+
+func TestEditableMessageDictionary(t *testing.T) {
+	dict := dictionary.Default().Clone()
+	dict.AddAVP(&dictionary.AVP{Name: "Acme-Record-Type", Code: 480, Type: dictionary.Enumerated,
+		Enums: []dictionary.Enum{{Name: "ACME_INTERIM", Value: 3}}})
+	m, err := NewEditableMessage(dict, d)
+	a.NilError(t, err)
+	a.Equal(t, m.Indexer().GetEnumeratedName(0, 480), "4")
+
+	n, err := m.Set("Acme-Record-Type", Enum{Value: 3})
+	a.NilError(t, err)
+	a.Equal(t, n, 1)
+	a.Equal(t, m.Indexer().GetEnum(0, 480), Enum{Value: 3, Name: "ACME_INTERIM"})
+	a.Equal(t, m.Indexer().First(0, 480).AttributeName, "Acme-Record-Type")
+
+	// the bundled dictionary doesn't know the name
+	m, err = NewEditableMessage(nil, d)
+	a.NilError(t, err)
+	_, err = m.Set("Acme-Record-Type", 1)
+	a.ErrorContains(t, err, "Acme-Record-Type")
+}
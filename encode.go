@@ -0,0 +1,313 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"math"
+	"net"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// Wire encoding of Diameter messages and AVPs, RFC 6733 sections 3 and 4.  Values are encoded by the AVP's type in
+// the dictionary, or for AVPs it doesn't know, by their Go type:
+//
+//	uint32, uint64, int32, int64          Unsigned32, Unsigned64, Integer32, Integer64
+//	float32, float64                      Float32, Float64
+//	string, []byte                        UTF8String, OctetString
+//	time.Time, net.IP, Enum               Time, Address, Enumerated
+//
+// Any integer type converts to any integer AVP type if the value fits, strings and []byte convert into each other.
+
+// ErrAvpEncode is returned (wrapped) for values that can't be encoded as their AVP.
+var ErrAvpEncode = errors.New("avp encode failure")
+
+// Diameter message header flags, RFC 6733 section 3
+const (
+	FlagRequest    uint8 = 0x80
+	FlagProxiable  uint8 = 0x40
+	FlagError      uint8 = 0x20
+	FlagRetransmit uint8 = 0x10
+)
+
+const (
+	diameterVersion   = 1
+	diameterHeaderLen = 20
+	avpHeaderLen      = 8
+	avpVendorLen      = 4
+	maxLen24          = 1<<24 - 1 // message and AVP lengths are 24 bits
+)
+
+var (
+	enumType = reflect.TypeOf(Enum{})
+	timeType = reflect.TypeOf(time.Time{})
+	ipType   = reflect.TypeOf(net.IP{})
+)
+
+// append the message header for a message with avps (the encoded AVPs) to b, followed by avps
+func appendMessage(b []byte, version, flags uint8, cmd, appId, hopByHop, endToEnd uint32, avps []byte) ([]byte, error) {
+	l := diameterHeaderLen + len(avps)
+	if l > maxLen24 {
+		return nil, fmt.Errorf("%w: message length %d exceeds 24 bits", ErrAvpEncode, l)
+	}
+	if cmd > maxLen24 {
+		return nil, fmt.Errorf("%w: command code %d exceeds 24 bits", ErrAvpEncode, cmd)
+	}
+	b = append(b, version, byte(l>>16), byte(l>>8), byte(l))
+	b = append(b, flags, byte(cmd>>16), byte(cmd>>8), byte(cmd))
+	b = appendUint32(b, appId)
+	b = appendUint32(b, hopByHop)
+	b = appendUint32(b, endToEnd)
+	return append(b, avps...), nil
+}
+
+// append an AVP with given data to b, padded to 4 bytes; the vendor id is present iff the V flag is set
+func appendAvp(b []byte, code uint32, flags uint8, vendorId uint32, data []byte) ([]byte, error) {
+	l := avpHeaderLen + len(data)
+	if flags&uint8(dictionary.FlagVendor) != 0 {
+		l += avpVendorLen
+	}
+	if l > maxLen24 {
+		return nil, fmt.Errorf("%w: %d/%d length %d exceeds 24 bits", ErrAvpEncode, vendorId, code, l)
+	}
+	b = appendUint32(b, code)
+	b = append(b, flags, byte(l>>16), byte(l>>8), byte(l))
+	if flags&uint8(dictionary.FlagVendor) != 0 {
+		b = appendUint32(b, vendorId)
+	}
+	b = append(b, data...)
+	for ; l%4 != 0; l++ {
+		b = append(b, 0)
+	}
+	return b, nil
+}
+
+// Encode message d in wire format.  Lengths and padding are computed from the AVP tree, so AVPs may have been
+// changed, added or removed since d was decoded; the Len, Padding and MessageLen fields are ignored.  A grouped AVP
+// (non-nil Grouped, or format Grouped) is encoded from its sub-AVPs, any other from its Data.  An AVP has a vendor
+// id iff its V flag is set.  Every AVP is padded to 4 bytes, except that one decoded without its padding as the last
+// AVP of its message or group is left unpadded again while it is still the last one, so that decoded messages encode
+// to the bytes they were decoded from.
+func Encode(d *Diameter) ([]byte, error) {
+	var avps []byte
+	var err error
+	for i, avp := range d.AVPs {
+		if avps, err = appendEncodedAvp(avps, avp, i == len(d.AVPs)-1); err != nil {
+			return nil, err
+		}
+	}
+	return appendMessage(nil, d.Version, d.Flags, d.CommandCode, d.ApplicationID, d.HopByHopID, d.EndToEndID, avps)
+}
+
+// Encode avp and its sub-AVPs in wire format, padding included, see Encode.
+func EncodeAVP(avp *AVP) ([]byte, error) {
+	return appendEncodedAvp(nil, avp, false)
+}
+
+// append avp to b; last if it's the last AVP of its message or group
+func appendEncodedAvp(b []byte, avp *AVP, last bool) ([]byte, error) {
+	if avp.Flags&uint8(dictionary.FlagVendor) == 0 && avp.VendorCode != 0 {
+		return nil, fmt.Errorf("%w: %d/%d has a vendor id but no V flag", ErrAvpEncode, avp.VendorCode,
+			avp.AttributeCode)
+	}
+	data := avp.Data
+	if _, grouped := avp.GetDecoder().(*DiameterGrouped); grouped || avp.Grouped != nil {
+		data = nil
+		var err error
+		for i, sub := range avp.Grouped {
+			if data, err = appendEncodedAvp(data, sub, i == len(avp.Grouped)-1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	b, err := appendAvp(b, avp.AttributeCode, avp.Flags, avp.VendorCode, data)
+	if err != nil || !last || !avp.unpadded {
+		return b, err
+	}
+	// headers are a multiple of 4 bytes, so the padding is that of the data
+	return b[:len(b)-(4-len(data)%4)%4], nil
+}
+
+// header flags of an AVP: those def requires, and V iff there's a vendor id
+func avpFlags(def *dictionary.AVP, vendorId uint32) uint8 {
+	var f dictionary.Flag
+	if def != nil {
+		f = def.Must
+	}
+	if vendorId != 0 {
+		f |= dictionary.FlagVendor
+	} else {
+		f &^= dictionary.FlagVendor
+	}
+	return uint8(f)
+}
+
+// AVP type of Go values of type t, for AVPs missing from the dictionary; false if there is none
+func avpTypeOf(t reflect.Type) (dictionary.Type, bool) {
+	switch t {
+	case enumType:
+		return dictionary.Enumerated, true
+	case timeType:
+		return dictionary.Time, true
+	case ipType:
+		return dictionary.Address, true
+	}
+	switch t.Kind() {
+	case reflect.Uint32:
+		return dictionary.Unsigned32, true
+	case reflect.Uint64:
+		return dictionary.Unsigned64, true
+	case reflect.Int32:
+		return dictionary.Integer32, true
+	case reflect.Int64:
+		return dictionary.Integer64, true
+	case reflect.Float32:
+		return dictionary.Float32, true
+	case reflect.Float64:
+		return dictionary.Float64, true
+	case reflect.String:
+		return dictionary.UTF8String, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return dictionary.OctetString, true
+		}
+	}
+	return dictionary.Unknown, false
+}
+
+// whether values of type t are encoded as one non grouped AVP
+func isScalarType(t reflect.Type) bool {
+	_, ok := avpTypeOf(t)
+	if !ok {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+			ok = true
+		}
+	}
+	return ok
+}
+
+// encode v as data of an AVP of type t
+func encodeData(t dictionary.Type, v reflect.Value) ([]byte, error) {
+	if v.Type() == enumType {
+		v = reflect.ValueOf(v.Interface().(Enum).Value)
+	}
+	mismatch := func() error {
+		return fmt.Errorf("%w: can't encode %s as %s", ErrAvpEncode, v.Type(), t)
+	}
+	switch t {
+	case dictionary.Unsigned32, dictionary.Enumerated:
+		u, err := uintOf(v, math.MaxUint32, t)
+		if err != nil {
+			return nil, err
+		}
+		return appendUint32(nil, uint32(u)), nil
+	case dictionary.Unsigned64:
+		u, err := uintOf(v, math.MaxUint64, t)
+		if err != nil {
+			return nil, err
+		}
+		return appendUint64(nil, u), nil
+	case dictionary.Integer32:
+		i, err := intOf(v, math.MinInt32, math.MaxInt32, t)
+		if err != nil {
+			return nil, err
+		}
+		return appendUint32(nil, uint32(i)), nil
+	case dictionary.Integer64:
+		i, err := intOf(v, math.MinInt64, math.MaxInt64, t)
+		if err != nil {
+			return nil, err
+		}
+		return appendUint64(nil, uint64(i)), nil
+	case dictionary.Float32, dictionary.Float64:
+		if k := v.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return nil, mismatch()
+		}
+		if t == dictionary.Float32 {
+			return appendUint32(nil, math.Float32bits(float32(v.Float()))), nil
+		}
+		return appendUint64(nil, math.Float64bits(v.Float())), nil
+	case dictionary.Time:
+		tm, ok := v.Interface().(time.Time)
+		if !ok {
+			return nil, mismatch()
+		}
+		// NTP seconds, wrapping after 2036; decodeData reads values below 1<<31 as the next era
+		secs := tm.Unix() + ntpEpochOffset
+		if secs < 1<<31 || secs >= 1<<32+1<<31 {
+			return nil, fmt.Errorf("%w: time %s out of range", ErrAvpEncode, tm)
+		}
+		return appendUint32(nil, uint32(secs)), nil
+	case dictionary.Address:
+		ip, ok := v.Interface().(net.IP)
+		if !ok {
+			return nil, mismatch()
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return append([]byte{0, 1}, ip4...), nil
+		}
+		if len(ip) == net.IPv6len {
+			return append([]byte{0, 2}, ip...), nil
+		}
+		return nil, fmt.Errorf("%w: bad IP address %v", ErrAvpEncode, ip)
+	case dictionary.UTF8String, dictionary.DiameterIdentity, dictionary.DiameterURI, dictionary.IPFilterRule,
+		dictionary.QoSFilterRule, dictionary.OctetString:
+		var b []byte
+		switch {
+		case v.Kind() == reflect.String:
+			b = []byte(v.String())
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			b = append([]byte(nil), v.Bytes()...)
+		default:
+			return nil, mismatch()
+		}
+		if t != dictionary.OctetString && !utf8.Valid(b) {
+			return nil, fmt.Errorf("%w: %q is not valid UTF-8", ErrAvpEncode, b)
+		}
+		return b, nil
+	}
+	return nil, mismatch()
+}
+
+func uintOf(v reflect.Value, max uint64, t dictionary.Type) (uint64, error) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= max {
+			return u, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i >= 0 && uint64(i) <= max {
+			return uint64(i), nil
+		}
+	default:
+		return 0, fmt.Errorf("%w: can't encode %s as %s", ErrAvpEncode, v.Type(), t)
+	}
+	return 0, fmt.Errorf("%w: %v out of range for %s", ErrAvpEncode, v.Interface(), t)
+}
+
+func intOf(v reflect.Value, min, max int64, t dictionary.Type) (int64, error) {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= uint64(max) {
+			return int64(u), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i := v.Int(); i >= min && i <= max {
+			return i, nil
+		}
+	default:
+		return 0, fmt.Errorf("%w: can't encode %s as %s", ErrAvpEncode, v.Type(), t)
+	}
+	return 0, fmt.Errorf("%w: %v out of range for %s", ErrAvpEncode, v.Interface(), t)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
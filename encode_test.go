@@ -0,0 +1,120 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	b, err := Encode(d)
+	a.NilError(t, err)
+	a.DeepEqual(t, b, testPacketDiameterAccountingRequest271)
+
+	// per AVP too, padding included
+	for _, avp := range d.AVPs {
+		ab, err := EncodeAVP(avp)
+		a.NilError(t, err)
+		a.Equal(t, uint32(len(ab)), avp.Len+avp.Padding)
+	}
+
+	// and for built messages
+	built, err := NewMessage(272, 4, true).
+		AVP(0, 263, "odd").
+		Group(10415, 873, func(g *GroupBuilder) {
+			g.Group(10415, 874, func(g *GroupBuilder) { g.AVP(10415, 2064, "node") })
+		}).
+		Bytes()
+	a.NilError(t, err)
+	dia, err := Decode(nil, built)
+	a.NilError(t, err)
+	b, err = Encode(dia)
+	a.NilError(t, err)
+	a.DeepEqual(t, b, built)
+}
+
+func TestEncodeUnpadded(t *testing.T) {
+	// the last AVP of the message, and of a group, without its padding
+	group := append(testAvp(t, 264, 0x40, 0, []byte("host")), testAvp(t, 1, 0x40, 0, []byte("u"))[:9]...)
+	var avps []byte
+	avps = append(avps, testAvp(t, 263, 0x40, 0, []byte("sid"))...)
+	avps = append(avps, testAvp(t, 443, 0x40, 0, group)...)
+	avps = append(avps, testAvp(t, 1, 0x40, 0, []byte("abcde"))[:13]...)
+	b, err := appendMessage(nil, 1, 0, 271, 3, 1, 2, avps)
+	a.NilError(t, err)
+
+	eager, err := Decode(nil, b)
+	a.NilError(t, err)
+	lazy, err := DecodeLazy(nil, b)
+	a.NilError(t, err)
+	for _, dia := range []*Diameter{eager, lazy} {
+		enc, err := Encode(dia)
+		a.NilError(t, err)
+		a.DeepEqual(t, enc, b)
+	}
+
+	// once they're not last they're padded
+	last := eager.AVPs[2]
+	eager.AVPs = append(eager.AVPs, eager.AVPs[0])
+	eager.AVPs[1].Grouped = append(eager.AVPs[1].Grouped, eager.AVPs[0])
+	enc, err := Encode(eager)
+	a.NilError(t, err)
+	back, err := Decode(nil, enc)
+	a.NilError(t, err)
+	a.Equal(t, back.AVPs[2].Padding, uint32(3))
+	a.Equal(t, back.AVPs[1].Grouped[1].Padding, uint32(3))
+	a.Equal(t, NewAvpIndexer(back).AtRoot().GetUTF8String(0, 1), "abcde")
+	ab, err := EncodeAVP(last)
+	a.NilError(t, err)
+	a.Equal(t, len(ab), 16)
+
+	// edits keep the lengths consistent with what Bytes encodes
+	m, err := NewEditableMessage(nil, lazy)
+	a.NilError(t, err)
+	_, err = m.Set("Session-Id", "sid;1")
+	a.NilError(t, err)
+	enc, err = m.Bytes()
+	a.NilError(t, err)
+	a.Equal(t, m.Diameter().MessageLen, uint32(len(enc)))
+	a.Equal(t, len(enc)%4, 1)
+	_, err = m.Insert(InsertAfter, "/User-Name", "Session-Id", "x")
+	a.NilError(t, err)
+	enc, err = m.Bytes()
+	a.NilError(t, err)
+	a.Equal(t, m.Diameter().MessageLen, uint32(len(enc)))
+}
+
+func TestEncodeModified(t *testing.T) {
+	dia, err := Decode(nil, testPacketDiameterAccountingRequest271)
+	a.NilError(t, err)
+
+	// longer value, removed sub-AVP, emptied group, added AVP
+	sid := NewAvpIndexer(dia).First(0, 263)
+	sid.Data = []byte("a longer session id;1;2;3")
+	ps := NewAvpIndexer(dia).FromGroup(10415, 873).First(10415, 874)
+	ps.Grouped = ps.Grouped[1:]
+	NewAvpIndexer(dia).First(0, 443).Grouped = []*AVP{}
+	dia.AVPs = append(dia.AVPs, &AVP{AttributeCode: 268, Flags: 0x40, Data: []byte{0, 0, 7, 0xd1}})
+
+	b, err := Encode(dia)
+	a.NilError(t, err)
+	back, err := Decode(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, back.MessageLen, uint32(len(b)))
+	ai := NewAvpIndexer(back)
+	a.Equal(t, ai.GetUTF8String(0, 263), "a longer session id;1;2;3")
+	a.Assert(t, ai.FromGroup(10415, 874).First(10415, 2) == nil)
+	a.Equal(t, ai.FromGroup(10415, 874).GetUTF8String(10415, 2064), "Sprint")
+	a.Equal(t, len(ai.FromGroup(10415, 873).First(0, 443).Grouped), 0)
+	a.Equal(t, ai.GetUint32(0, 268), uint32(2001))
+	a.DeepEqual(t, ai.FromGroup(10415, 2040).GetAllUint32(0, 432), []uint32{0, 4001})
+}
+
+func TestEncodeErrors(t *testing.T) {
+	_, err := EncodeAVP(&AVP{AttributeCode: 1, VendorCode: 10415, Data: []byte{1}})
+	a.Assert(t, errors.Is(err, ErrAvpEncode))
+	a.ErrorContains(t, err, "no V flag")
+
+	_, err = EncodeAVP(&AVP{AttributeCode: 1, Data: make([]byte, 1<<24)})
+	a.ErrorContains(t, err, "exceeds 24 bits")
+}
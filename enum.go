@@ -0,0 +1,146 @@
+package avpindexer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"strconv"
+	"strings"
+)
+
+// Enumerated values with their dictionary names, so logs can show CC-Request-Type TERMINATION_REQUEST instead
+// of 3.
+//
+//	ai.GetEnumeratedName(0, 416)                  "TERMINATION_REQUEST"
+//	e := ai.GetEnum(0, 416)                        e.Value == 3, e.String() == "TERMINATION_REQUEST"
+//	e, err := ParseEnum(nil, 0, 416, "TERMINATION_REQUEST")
+
+// Enum is an Enumerated AVP value and its name in the dictionary, empty if the dictionary doesn't name it.
+type Enum struct {
+	Value uint32
+	Name  string
+}
+
+// name of the value, or the number if it has no name
+func (e Enum) String() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return strconv.FormatUint(uint64(e.Value), 10)
+}
+
+// name and number, "TERMINATION_REQUEST (3)", or just the number if it has no name
+func (e Enum) display() string {
+	if e.Name != "" {
+		return fmt.Sprintf("%s (%d)", e.Name, e.Value)
+	}
+	return strconv.FormatUint(uint64(e.Value), 10)
+}
+
+// Resolve s, an enumeration name (matched case insensitively) or number, to an Enum of the AVP with given id using
+// d (dictionary.Default() if nil); for building filters from user input.
+func ParseEnum(d *dictionary.Dictionary, vendorId, attrId uint32, s string) (Enum, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	def := d.AVP(vendorId, attrId)
+	if def == nil {
+		return Enum{}, fmt.Errorf("%w %d/%d", dictionary.ErrUnknownAVP, vendorId, attrId)
+	}
+	if def.Type != dictionary.Enumerated {
+		return Enum{}, fmt.Errorf("%s is %s, not Enumerated", def.Name, def.Type)
+	}
+	s = strings.TrimSpace(s)
+	if v, ok := def.EnumValue(s); ok {
+		n, _ := def.EnumName(v)
+		return Enum{Value: v, Name: n}, nil
+	}
+	if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+		n, _ := def.EnumName(uint32(v))
+		return Enum{Value: uint32(v), Name: n}, nil
+	}
+	return Enum{}, fmt.Errorf("%s has no value %q", def.Name, s)
+}
+
+// enumeration value of avp and its name in d; false if avp isn't a 4 byte Enumerated AVP, per d or its decoder
+func enumOf(d *dictionary.Dictionary, avp *AVP) (Enum, bool) {
+	if avp == nil || len(avp.Data) != 4 || len(avp.Grouped) > 0 {
+		return Enum{}, false
+	}
+	def := d.AVP(avp.VendorCode, avp.AttributeCode)
+	if def == nil {
+		if _, ok := avp.GetDecoder().(*DiameterEnumerated); !ok {
+			return Enum{}, false
+		}
+		return Enum{Value: binary.BigEndian.Uint32(avp.Data)}, true
+	}
+	if def.Type != dictionary.Enumerated {
+		return Enum{}, false
+	}
+	e := Enum{Value: binary.BigEndian.Uint32(avp.Data)}
+	e.Name, _ = def.EnumName(e.Value)
+	return e, true
+}
+
+// retrieve first matching enumerated value with given id and its dictionary name, or the zero Enum
+func (ai AvpIndexer) GetEnum(vendorId, attrId uint32) Enum {
+	e, _ := enumAt(ai.at(vendorId, attrId))
+	return e
+}
+
+// retrieve first matching enumerated value with given id and its dictionary name, or the zero Enum
+func (aip avpIndexerWithPath) GetEnum(vendorId, attrId uint32) Enum {
+	e, _ := enumAt(aip.at(vendorId, attrId))
+	return e
+}
+
+// retrieve first matching enumerated value and its dictionary name, or the zero Enum
+func (q avpQuery) GetEnum() Enum {
+	e, _ := enumAt(q.ai, q.path)
+	return e
+}
+
+// retrieve dictionary name of first matching enumerated value with given id, the number if the dictionary doesn't
+// name it, or "" if there is no such AVP
+func (ai AvpIndexer) GetEnumeratedName(vendorId, attrId uint32) string {
+	return enumName(enumAt(ai.at(vendorId, attrId)))
+}
+
+// retrieve dictionary name of first matching enumerated value with given id, the number if the dictionary doesn't
+// name it, or "" if there is no such AVP
+func (aip avpIndexerWithPath) GetEnumeratedName(vendorId, attrId uint32) string {
+	return enumName(enumAt(aip.at(vendorId, attrId)))
+}
+
+// retrieve dictionary name of first matching enumerated value, the number if the dictionary doesn't name it, or ""
+// if there is no such AVP
+func (q avpQuery) GetEnumeratedName() string {
+	return enumName(enumAt(q.ai, q.path))
+}
+
+// first AVP matching path that is Enumerated, skipping others as the typed getters do, named by ai's dictionary
+func enumAt(ai AvpIndexer, path *pathElement) (Enum, bool) {
+	avp, _ := firstOfType[*DiameterEnumerated](ai, path)
+	return enumOf(ai.dictionary(), avp)
+}
+
+func enumName(e Enum, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return e.String()
+}
+
+// value of a non grouped AVP as shown by PrintAvp and the JSON exporters: enumerated values with their name in the
+// dictionary the AVP was decoded with
+func displayValue(avp *AVP) string {
+	d := avp.dict
+	if d == nil {
+		d = dictionary.Default()
+	}
+	if e, ok := enumOf(d, avp); ok {
+		return e.display()
+	}
+	avp.GetDecoder() // lazily decoded AVPs fill DecodedValue on first use
+	return avp.DecodedValue
+}
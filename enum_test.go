@@ -0,0 +1,97 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestGetEnum(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	e := ai.GetEnum(0, 480)
+	a.Equal(t, e, Enum{Value: 4, Name: "STOP_RECORD"})
+	a.Equal(t, e.String(), "STOP_RECORD")
+	a.Equal(t, ai.GetEnumeratedName(0, 480), "STOP_RECORD")
+	a.Equal(t, ai.FromGroup(0, 443).GetEnumeratedName(0, 450), "END_USER_E164")
+	a.Equal(t, ai.Query("10415/873/10415/874/10415/2051").GetEnum().Value, ai.GetEnumerated(10415, 2051))
+
+	// not enumerated, or not there
+	a.Equal(t, ai.GetEnum(0, 485), Enum{})
+	a.Equal(t, ai.GetEnumeratedName(0, 485), "")
+	a.Equal(t, ai.GetEnumeratedName(0, 999), "")
+
+	// matches of other types are passed over, as by GetEnumerated
+	a.Equal(t, ai.AtRoot().GetEnum(0, Wildcard), Enum{Value: 4, Name: "STOP_RECORD"})
+	a.Equal(t, ai.Query("/0/*").GetEnumeratedName(), "STOP_RECORD")
+
+	// values the dictionary doesn't name show as numbers
+	ai = ai.WithDictionary(dictionary.New())
+	a.Equal(t, ai.GetEnum(0, 480), Enum{Value: 4})
+	a.Equal(t, ai.GetEnumeratedName(0, 480), "4")
+}
+
+func TestParseEnum(t *testing.T) {
+	e, err := ParseEnum(nil, 0, 416, "termination_request")
+	a.NilError(t, err)
+	a.Equal(t, e, Enum{Value: 3, Name: "TERMINATION_REQUEST"})
+	e, err = ParseEnum(nil, 0, 416, "2")
+	a.NilError(t, err)
+	a.Equal(t, e, Enum{Value: 2, Name: "UPDATE_REQUEST"})
+	e, err = ParseEnum(nil, 0, 416, "77")
+	a.NilError(t, err)
+	a.Equal(t, e.String(), "77")
+
+	_, err = ParseEnum(nil, 0, 416, "SOMETIMES")
+	a.ErrorContains(t, err, `CC-Request-Type has no value "SOMETIMES"`)
+	_, err = ParseEnum(nil, 0, 432, "1")
+	a.ErrorContains(t, err, "Rating-Group is Unsigned32, not Enumerated")
+	_, err = ParseEnum(nil, 0, 999, "1")
+	a.ErrorContains(t, err, "unknown avp 0/999")
+}
+
+func TestEnumDisplay(t *testing.T) {
+	ai := NewAvpIndexer(d)
+	a.Equal(t, displayValue(ai.First(0, 480)), "STOP_RECORD (4)")
+	a.Equal(t, displayValue(ai.First(0, 485)), "1")
+
+	js := JsonFromAvpFields(d.AVPs, []string{"Accounting-Record-Type", "Subscription-Id-Type"})
+	a.Equal(t, js, `{"Accounting-Record-Type":"STOP_RECORD (4)","Subscription-Id-Type":"END_USER_E164 (0)"}`)
+}
+
+func TestEnumDictionary(t *testing.T) {
+	acme := dictionary.Default().Clone()
+	acme.AddAVP(&dictionary.AVP{Name: "Subscription-Id-Type", Code: 450, Type: dictionary.Enumerated,
+		Enums: []dictionary.Enum{{Name: "ACME_E164", Value: 0}}})
+	want := Enum{Value: 0, Name: "ACME_E164"}
+	type subscription struct {
+		Type Enum `avp:"0/450"`
+	}
+	var msg struct {
+		Type Enum `avp:"0/443/0/450"`
+	}
+
+	eager, err := Decode(acme, testPacketDiameterAccountingRequest271)
+	a.NilError(t, err)
+	lazy, err := NewLazyAvpIndexer(acme, testPacketDiameterAccountingRequest271)
+	a.NilError(t, err)
+	for name, ai := range map[string]AvpIndexer{
+		"decoded": NewAvpIndexer(eager),
+		"lazy":    lazy,
+		"with":    NewAvpIndexer(d).WithDictionary(acme),
+	} {
+		a.Equal(t, ai.FromGroup(0, 443).GetEnum(0, 450), want, name)
+		a.Equal(t, Get[Enum](ai.FromGroup(0, 443), 0, 450), want, name)
+		a.NilError(t, UnmarshalIndexer(ai, &msg), name)
+		a.Equal(t, msg.Type, want, name)
+		subs, err := TableOf[subscription](ai, "0/443")
+		a.NilError(t, err, name)
+		a.Equal(t, subs[0].Type, want, name)
+	}
+
+	a.NilError(t, Unmarshal(eager, &msg))
+	a.Equal(t, msg.Type, want)
+	a.Equal(t, displayValue(NewAvpIndexer(eager).FromGroup(0, 443).First(0, 450)), "ACME_E164 (0)")
+	a.Equal(t, displayValue(lazy.FromGroup(0, 443).First(0, 450)), "ACME_E164 (0)")
+	a.Equal(t, Get[Enum](NewAvpIndexer(d).FromGroup(0, 443), 0, 450).Name, "END_USER_E164")
+}
@@ -0,0 +1,224 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Generic typed retrieval.  The Go type asked for selects how a matching AVP is decoded, via a registry that maps
+// result types to Diameter decoder types; applications can register their own result types with RegisterType.
+// Unregistered named types over uint32, string etc are read as their underlying type.
+//
+//	rg := Get[uint32](ai.FromGroup(10415, 2040), 0, 432)
+//	ts, err := GetE[time.Time](ai, 10415, 2043)
+//	ids := GetAll[string](ai.FromGroup(0, 443), 0, 444)
+
+// ErrUnregisteredType is returned by GetE for a result type with no registered decoder.
+var ErrUnregisteredType = errors.New("no decoder registered for type")
+
+// decodes avp; d is the dictionary of the indexer avp was found with, for the types that need names (Enum)
+type decodeFunc func(d *dictionary.Dictionary, avp *AVP) (interface{}, error)
+
+var typeRegistry = struct {
+	sync.RWMutex
+	m map[reflect.Type]decodeFunc
+}{m: make(map[reflect.Type]decodeFunc)}
+
+// Register decode as the way to convert an AVP to a T, replacing any previous registration for T (including the
+// built in ones).  decode is only called for matching AVPs; return an *AvpError (or any error) if avp can't be
+// represented as a T.
+func RegisterType[T any](decode func(avp *AVP) (T, error)) {
+	registerDecoder[T](func(_ *dictionary.Dictionary, avp *AVP) (interface{}, error) {
+		return decode(avp)
+	})
+}
+
+func registerDecoder[T any](decode decodeFunc) {
+	typeRegistry.Lock()
+	defer typeRegistry.Unlock()
+	typeRegistry.m[reflect.TypeOf((*T)(nil)).Elem()] = decode
+}
+
+func registeredDecoder[T any]() decodeFunc {
+	return scalarDecoder(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func decoderForType(t reflect.Type) decodeFunc {
+	typeRegistry.RLock()
+	defer typeRegistry.RUnlock()
+	return typeRegistry.m[t]
+}
+
+// builtin types registered by default, by kind, for reading named types such as `type Plan uint32`
+var kindTypes = map[reflect.Kind]reflect.Type{
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// decoder for values of type t: its registered decoder or, converting to t, that of its underlying builtin type; nil
+// if there is neither
+func scalarDecoder(t reflect.Type) decodeFunc {
+	if dec := decoderForType(t); dec != nil {
+		return dec
+	}
+	bt, ok := kindTypes[t.Kind()]
+	if !ok {
+		return nil
+	}
+	dec := decoderForType(bt)
+	if dec == nil {
+		return nil
+	}
+	return func(d *dictionary.Dictionary, avp *AVP) (interface{}, error) {
+		v, err := dec(d, avp)
+		if err != nil {
+			return v, err
+		}
+		return reflect.ValueOf(v).Convert(t).Interface(), nil
+	}
+}
+
+// built in result types, matching the typed Get* methods
+func init() {
+	RegisterType(func(avp *AVP) (uint32, error) {
+		if dec, ok := decoderAs[*DiameterEnumerated](avp); ok {
+			return dec.Get(), nil
+		}
+		return valueOf[*DiameterUnsigned32, uint32](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (uint64, error) {
+		return valueOf[*DiameterUnsigned64, uint64](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (int32, error) {
+		return valueOf[*DiameterInteger32, int32](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (int64, error) {
+		return valueOf[*DiameterInteger64, int64](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (float32, error) {
+		return valueOf[*DiameterFloat32, float32](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (float64, error) {
+		return valueOf[*DiameterFloat64, float64](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (time.Time, error) {
+		return valueOf[*DiameterTime, time.Time](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (string, error) {
+		return valueOf[*DiameterOctetString, string](nil, avp)
+	})
+	RegisterType(func(avp *AVP) (net.IP, error) {
+		return valueOf[*DiameterIPAddress, net.IP](nil, avp)
+	})
+	// raw value of any non grouped AVP
+	RegisterType(func(avp *AVP) ([]byte, error) {
+		if len(avp.Grouped) > 0 {
+			return nil, &AvpError{Kind: ErrAvpTypeMismatch, Avp: avp, Wanted: "[]byte"}
+		}
+		return avp.Data, nil
+	})
+	// enumerated value named by the indexer's dictionary
+	registerDecoder[Enum](func(d *dictionary.Dictionary, avp *AVP) (interface{}, error) {
+		if e, ok := enumOf(d, avp); ok {
+			return e, nil
+		}
+		return Enum{}, &AvpError{Kind: ErrAvpTypeMismatch, Avp: avp, Wanted: "Enumerated"}
+	})
+	RegisterType(func(avp *AVP) (*AVP, error) {
+		return avp, nil
+	})
+}
+
+func decodeAs[T any](decode decodeFunc, d *dictionary.Dictionary, path *pathElement, avp *AVP) (T, error) {
+	var zero T
+	v, err := decode(d, avp)
+	if err != nil {
+		var ae *AvpError
+		if errors.As(err, &ae) && ae.Path == "" {
+			ae.Path = Path{leaf: path}.String()
+		}
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+func leafPath(parent *pathElement, vendorId, attrId uint32) *pathElement {
+	return &pathElement{
+		avpId:  avpId{vendorId: vendorId, attrId: attrId},
+		parent: parent,
+	}
+}
+
+// retrieve first matching value of type T with given id, or the default/zero value for that type
+func Get[T any](ix Indexer, vendorId, attrId uint32) T {
+	v, _ := GetE[T](ix, vendorId, attrId)
+	return v
+}
+
+// retrieve first matching value of type T with given id; false if there is no such AVP or it can't be read as a T
+func Lookup[T any](ix Indexer, vendorId, attrId uint32) (T, bool) {
+	v, err := GetE[T](ix, vendorId, attrId)
+	return v, err == nil
+}
+
+// retrieve first matching value of type T with given id, or an error and the zero value for that type.  As with the
+// typed getters, matching AVPs of a type that can't be read as a T are skipped.
+func GetE[T any](ix Indexer, vendorId, attrId uint32) (T, error) {
+	var zero T
+	decode := registeredDecoder[T]()
+	if decode == nil {
+		return zero, fmt.Errorf("%w %T", ErrUnregisteredType, zero)
+	}
+	ai, parent := ix.scope()
+	d := ai.dictionary()
+	path := leafPath(parent, vendorId, attrId)
+	var v T
+	var err, mismatch error
+	found := false
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		v, err = decodeAs[T](decode, d, path, pe.avp)
+		if errors.Is(err, ErrAvpTypeMismatch) {
+			if mismatch == nil {
+				mismatch = err
+			}
+			return true
+		}
+		found = true
+		return false
+	})
+	switch {
+	case found:
+		return v, err
+	case mismatch != nil:
+		return zero, mismatch
+	}
+	return zero, &AvpError{Kind: ErrAvpNotFound, Path: Path{leaf: path}.String()}
+}
+
+// retrieve values of type T of all matching AVPs, in message order.  AVPs that can't be read as a T are skipped.
+func GetAll[T any](ix Indexer, vendorId, attrId uint32) []T {
+	decode := registeredDecoder[T]()
+	if decode == nil {
+		return nil
+	}
+	ai, parent := ix.scope()
+	d := ai.dictionary()
+	path := leafPath(parent, vendorId, attrId)
+	var vs []T
+	ai.visitIntfcp(path, func(avp *AVP) {
+		if v, err := decodeAs[T](decode, d, path, avp); err == nil {
+			vs = append(vs, v)
+		}
+	})
+	return vs
+}
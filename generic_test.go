@@ -0,0 +1,61 @@
+package avpindexer
+
+import (
+	"encoding/binary"
+	"errors"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGeneric(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, Get[uint32](ai, 0, 485), uint32(1))
+	a.Equal(t, Get[uint32](ai, 0, 480), ai.GetEnumerated(0, 480))
+	a.Equal(t, Get[uint32](ai.FromGroup(10415, 2040), 10415, 2045), uint32(241))
+	a.Equal(t, Get[uint64](ai.FromGroup(10415, 2040), 0, 364), uint64(3208))
+	a.Equal(t, Get[int32](ai.FromGroup(10415, 2040), 10415, 2037), ai.FromGroup(10415, 2040).GetInt32(10415, 2037))
+	a.Equal(t, Get[string](ai, 0, 263), ai.GetUTF8String(0, 263))
+	a.Equal(t, Get[time.Time](ai, 0, 55), ai.GetTime(0, 55))
+	a.DeepEqual(t, Get[net.IP](ai.FromGroup(10415, 874), 10415, 1228), ai.FromGroup(10415, 874).GetIPAddress(10415, 1228))
+	a.DeepEqual(t, Get[[]byte](ai, 0, 485), []byte{0, 0, 0, 1})
+	a.Equal(t, Get[*AVP](ai, 10415, 873).AttributeCode, uint32(873))
+
+	v, ok := Lookup[uint32](ai, 0, 432)
+	a.Assert(t, ok)
+	a.Equal(t, v, uint32(0))
+	_, ok = Lookup[uint32](ai, 0, 999)
+	a.Assert(t, !ok)
+
+	_, err := GetE[uint64](ai, 0, 485)
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+	a.ErrorContains(t, err, "0/485: ")
+	_, err = GetE[uint64](ai.FromGroup(10415, 874), 0, 364)
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+	_, err = GetE[complex64](ai, 0, 485)
+	a.Assert(t, errors.Is(err, ErrUnregisteredType))
+
+	a.DeepEqual(t, GetAll[uint64](ai.FromGroup(10415, 2040), 0, 364), []uint64{3208, 26694})
+	a.DeepEqual(t, GetAll[uint32](ai, 10415, 2045), []uint32{241, 600})
+	a.Equal(t, len(GetAll[uint64](ai, 10415, 2045)), 0)
+}
+
+type testRatingGroup uint32
+
+func TestRegisterType(t *testing.T) {
+	RegisterType(func(avp *AVP) (testRatingGroup, error) {
+		if len(avp.Data) != 4 {
+			return 0, &AvpError{Kind: ErrAvpDecode, Avp: avp}
+		}
+		return testRatingGroup(binary.BigEndian.Uint32(avp.Data)), nil
+	})
+
+	ai := NewAvpIndexer(d)
+	a.DeepEqual(t, GetAll[testRatingGroup](ai.FromGroup(10415, 2040), 0, 432), []testRatingGroup{0, 4001})
+
+	_, err := GetE[testRatingGroup](ai, 0, 263)
+	a.Assert(t, errors.Is(err, ErrAvpDecode))
+	a.ErrorContains(t, err, "0/263: ")
+}
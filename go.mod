@@ -1,12 +1,10 @@
 module github.com/rjm2718/avpindexer
 
-go 1.13
+go 1.18
+
+require gotest.tools v2.2.0+incompatible
 
 require (
 	github.com/google/go-cmp v0.4.1 // indirect
-	github.com/google/gopacket v1.1.17
 	github.com/pkg/errors v0.9.1 // indirect
-	gotest.tools v2.2.0+incompatible
 )
-
-replace github.com/google/gopacket => /home/ryan/go/src/github.com/rjm2718/gopacket
@@ -0,0 +1,29 @@
+// Package gopacketadapter decodes Diameter messages out of packets captured with gopacket, for applications that
+// already capture with it.  gopacket has no Diameter layer of its own; the message is read from the packet's
+// application payload (TCP or SCTP data) with avpindexer.Decode.  It is a separate module so that avpindexer itself
+// doesn't depend on gopacket.
+//
+//	dia, err := gopacketadapter.FromPacket(pkt, nil)
+//	ai := avpindexer.NewAvpIndexer(dia)
+package gopacketadapter
+
+import (
+	"errors"
+	"github.com/google/gopacket"
+	"github.com/rjm2718/avpindexer"
+	"github.com/rjm2718/avpindexer/dictionary"
+)
+
+// ErrNoDiameterLayer is returned by FromPacket for packets without an application payload to decode.
+var ErrNoDiameterLayer = errors.New("no Diameter layer in packet")
+
+// Decode the Diameter message carried by p, with AVP types from d (dictionary.Default() if nil).  The payload must
+// start with the message; bytes past its length, such as further messages in the same TCP segment, are ignored.
+// The result shares no memory with p, see avpindexer.Decode.
+func FromPacket(p gopacket.Packet, d *dictionary.Dictionary) (*avpindexer.Diameter, error) {
+	app := p.ApplicationLayer()
+	if app == nil || len(app.Payload()) == 0 {
+		return nil, ErrNoDiameterLayer
+	}
+	return avpindexer.Decode(d, app.Payload())
+}
@@ -0,0 +1,57 @@
+package gopacketadapter
+
+import (
+	"errors"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/rjm2718/avpindexer"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+)
+
+// an Ethernet/IPv4/TCP packet carrying payload to the Diameter port
+func tcpPacket(t *testing.T, payload []byte) gopacket.Packet {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP,
+		SrcIP: net.IP{10, 0, 0, 1}, DstIP: net.IP{10, 0, 0, 2}}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 3868, PSH: true, ACK: true, Window: 1024}
+	a.NilError(t, tcp.SetNetworkLayerForChecksum(ip))
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	a.NilError(t, gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload)))
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestFromPacket(t *testing.T) {
+	b, err := avpindexer.NewMessage(271, 3, true).Ids(1, 2).
+		AVP(0, 263, "sess;1").
+		AVP(0, 485, uint32(7)).
+		Group(10415, 873, func(g *avpindexer.GroupBuilder) {
+			g.Group(10415, 874, func(g *avpindexer.GroupBuilder) { g.AVP(10415, 2064, "Sprint") })
+		}).
+		Bytes()
+	a.NilError(t, err)
+
+	dia, err := FromPacket(tcpPacket(t, b), nil)
+	a.NilError(t, err)
+	a.Equal(t, dia.CommandCode, uint32(271))
+	a.Equal(t, dia.HopByHopID, uint32(1))
+	ai := avpindexer.NewAvpIndexer(dia)
+	a.Equal(t, ai.GetUTF8String(0, 263), "sess;1")
+	a.Equal(t, ai.GetUint32(0, 485), uint32(7))
+	a.Equal(t, ai.Query("**/10415/2064").GetUTF8String(), "Sprint")
+
+	again, err := avpindexer.Encode(dia)
+	a.NilError(t, err)
+	a.DeepEqual(t, again, b)
+
+	_, err = FromPacket(tcpPacket(t, nil), nil)
+	a.Equal(t, err, ErrNoDiameterLayer)
+	_, err = FromPacket(tcpPacket(t, []byte("GET / HTTP/1.1\r\n\r\n")), nil)
+	a.Assert(t, errors.Is(err, avpindexer.ErrMalformed))
+}
@@ -0,0 +1,16 @@
+module github.com/rjm2718/avpindexer/gopacketadapter
+
+go 1.18
+
+require (
+	github.com/google/gopacket v1.1.19
+	github.com/rjm2718/avpindexer v0.0.0
+	gotest.tools v2.2.0+incompatible
+)
+
+require (
+	github.com/google/go-cmp v0.4.1 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+)
+
+replace github.com/rjm2718/avpindexer => ../
@@ -0,0 +1,92 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+)
+
+// Per instance iteration over repeated grouped AVPs.  FromGroup(10415, 2040) matches every Service-Data-Container
+// at once; EachGroup instead yields one sub-indexer per container, so values can be paired up row by row:
+//
+//	ai.FromGroup(10415, 874).EachGroup(10415, 2040, func(sdc Indexer) {
+//		rg := sdc.GetUint32(0, 432)
+//		out := sdc.GetUint64(0, 364)
+//	})
+
+// return indexer whose retrieval operations are scoped to the sub-AVPs of one grouped AVP instance
+func (ai AvpIndexer) groupInstance(pe pathElementLeafNode) avpIndexerWithPath {
+	return avpIndexerWithPath{
+		AvpIndexer: ai,
+		parent:     &pathElement{kind: kindNode, node: pe.group},
+	}
+}
+
+// whether avp is a grouped AVP, sub-AVPs or not: by its sub-AVPs, its decoder or its definition in d
+func isGrouped(d *dictionary.Dictionary, avp *AVP) bool {
+	if avp.Grouped != nil {
+		return true
+	}
+	// lazily decoded AVPs that aren't grouped have no decoder until first used
+	if avp.lazy == nil {
+		if _, ok := avp.decoder.(*DiameterGrouped); ok {
+			return true
+		}
+	}
+	def := d.AVP(avp.VendorCode, avp.AttributeCode)
+	return def != nil && def.Type == dictionary.Grouped
+}
+
+func (ai AvpIndexer) eachGroupp(path *pathElement, f func(sub Indexer)) int {
+	if path == nil {
+		return 0
+	}
+	d := ai.dictionary()
+	var cc int
+	ai.visitNodes(path, func(pe pathElementLeafNode) {
+		if isGrouped(d, pe.avp) {
+			cc++
+			f(ai.groupInstance(pe))
+		}
+	})
+	return cc
+}
+
+func (ai AvpIndexer) groupsp(path *pathElement) []Indexer {
+	var subs []Indexer
+	ai.eachGroupp(path, func(sub Indexer) {
+		subs = append(subs, sub)
+	})
+	return subs
+}
+
+// invoke f for each matching grouped AVP, with an indexer scoped to that one instance.  returns number of times f
+// was invoked.
+func (ai AvpIndexer) EachGroup(vendorId, attrId uint32, f func(sub Indexer)) int {
+	return ai.eachGroupp(leafPath(nil, vendorId, attrId), f)
+}
+
+// invoke f for each matching grouped AVP, with an indexer scoped to that one instance.  returns number of times f
+// was invoked.
+func (aip avpIndexerWithPath) EachGroup(vendorId, attrId uint32, f func(sub Indexer)) int {
+	return aip.eachGroupp(leafPath(aip.parent, vendorId, attrId), f)
+}
+
+// invoke f for each matching grouped AVP, with an indexer scoped to that one instance.  returns number of times f
+// was invoked.
+func (q avpQuery) EachGroup(f func(sub Indexer)) int {
+	return q.ai.eachGroupp(q.path, f)
+}
+
+// return an indexer scoped to each matching grouped AVP instance, in message order; for use with range.
+func (ai AvpIndexer) Groups(vendorId, attrId uint32) []Indexer {
+	return ai.groupsp(leafPath(nil, vendorId, attrId))
+}
+
+// return an indexer scoped to each matching grouped AVP instance, in message order; for use with range.
+func (aip avpIndexerWithPath) Groups(vendorId, attrId uint32) []Indexer {
+	return aip.groupsp(leafPath(aip.parent, vendorId, attrId))
+}
+
+// return an indexer scoped to each matching grouped AVP instance, in message order; for use with range.
+func (q avpQuery) Groups() []Indexer {
+	return q.ai.groupsp(q.path)
+}
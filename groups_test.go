@@ -0,0 +1,95 @@
+package avpindexer
+
+import (
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestEachGroup(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	var rgs []uint32
+	var outs []uint64
+	n := ai.FromGroup(10415, 874).EachGroup(10415, 2040, func(sdc Indexer) {
+		rgs = append(rgs, sdc.GetUint32(0, 432))
+		outs = append(outs, sdc.GetUint64(0, 364))
+		a.Equal(t, sdc.Count(0, 364), 1)
+		a.Equal(t, sdc.AccumulateUint64(0, 364), outs[len(outs)-1])
+	})
+	a.Equal(t, n, 2)
+	a.DeepEqual(t, rgs, []uint32{0, 4001})
+	a.DeepEqual(t, outs, []uint64{3208, 26694})
+
+	// non grouped matches are not yielded
+	a.Equal(t, ai.EachGroup(0, 364, func(Indexer) { t.Fail() }), 0)
+	a.Equal(t, ai.Query("**/10415/2040").EachGroup(func(Indexer) {}), 2)
+}
+
+func TestGroups(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	subs := ai.Groups(10415, 2040)
+	a.Equal(t, len(subs), 2)
+	a.Equal(t, subs[0].GetUint32(10415, 2045), uint32(241))
+	a.Equal(t, subs[1].GetUint32(10415, 2045), uint32(600))
+	a.DeepEqual(t, Get[uint64](subs[1], 0, 366), uint64(0x49))
+	a.Equal(t, subs[1].Query("0/432").GetUint32(), uint32(4001))
+	_, err := subs[1].GetUint32E(0, 999)
+	a.Equal(t, err.Error(), "/10415/873/10415/874/10415/2040/0/999: avp not found")
+
+	// sub-indexers nest, and can look further down with Descendants
+	svc := ai.Groups(10415, 873)
+	a.Equal(t, len(svc), 1)
+	a.Equal(t, len(svc[0].Groups(10415, 2040)), 0)
+	ps := svc[0].Groups(10415, 874)
+	a.Equal(t, len(ps), 1)
+	a.DeepEqual(t, ps[0].GetAllUint32(10415, 2045), []uint32(nil))
+	a.DeepEqual(t, ps[0].FromGroup(10415, 2040).GetAllUint32(10415, 2045), []uint32{241, 600})
+	a.DeepEqual(t, svc[0].FromGroup(10415, 874).Descendants().GetAllUint32(0, 432), []uint32{0, 4001})
+
+	sdc := ps[0].Groups(10415, 2040)
+	a.Equal(t, sdc[1].GetUint32(0, 432), uint32(4001))
+	a.Equal(t, len(ai.Query("10415/874/10415/2040").Groups()), 2)
+}
+
+func TestEmptyGroup(t *testing.T) {
+	// a CCR whose MSCC requests units with an empty Requested-Service-Unit
+	b, err := NewMessage(272, 4, true).
+		AVP(0, 263, "sid").
+		Group(0, 456, func(g *GroupBuilder) {
+			g.Group(0, 437, func(*GroupBuilder) {})
+			g.AVP(0, 432, uint32(7))
+		}).
+		Bytes()
+	a.NilError(t, err)
+	eager, err := Decode(nil, b)
+	a.NilError(t, err)
+	lazy, err := NewLazyAvpIndexer(nil, b)
+	a.NilError(t, err)
+
+	type rsu struct {
+		Time *uint32 `avp:"0/420"`
+	}
+	type mscc struct {
+		RatingGroup uint32 `avp:"0/432"`
+		RSU         *rsu   `avp:"0/437"`
+	}
+	for name, ai := range map[string]AvpIndexer{"eager": NewAvpIndexer(eager), "lazy": lazy} {
+		a.Assert(t, ai.Exists(0, 437), name)
+		a.Equal(t, len(ai.Groups(0, 437)), 1, name)
+		a.Equal(t, ai.EachGroup(0, 437, func(sub Indexer) {
+			a.Equal(t, sub.Count(Wildcard, Wildcard), 0, name)
+		}), 1, name)
+		a.Equal(t, ai.EachGroup(0, 432, func(Indexer) {}), 0, name)
+
+		var m struct {
+			MSCC mscc `avp:"0/456"`
+		}
+		a.NilError(t, UnmarshalIndexer(ai, &m), name)
+		a.Assert(t, m.MSCC.RSU != nil, name)
+		a.Equal(t, m.MSCC.RatingGroup, uint32(7), name)
+		rows, err := TableOf[rsu](ai, "0/456/0/437")
+		a.NilError(t, err, name)
+		a.Equal(t, len(rows), 1, name)
+	}
+}
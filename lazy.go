@@ -0,0 +1,202 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+	"sync"
+	"sync/atomic"
+)
+
+// Lazy decoding.  DecodeLazy frames a message's AVPs -- header fields, names and types from the dictionary, the
+// grouped AVP tree -- over the caller's buffer without copying it, and leaves each value undecoded until a getter
+// asks for it.  A message where 3 values of 300 are read pays for 3 decoders and 3 DecodedValue strings rather than
+// 300, and all its AVPs come from one slab rather than one allocation each.  On its own that makes for fewer
+// allocations but not less work: a fresh DecodeLazy or NewLazyAvpIndexer costs about as much as Decode, since the
+// slabs and index are new each time.  The gain is in reusing them with ResetBytes, which for a stream of messages
+// brings framing down to a few small allocations per message (see BenchmarkLazyDecode).
+//
+//	ai, err := NewLazyAvpIndexer(nil, packet)
+//	v := ai.GetUint32(0, 415)               only this AVP's value is decoded
+//
+//	err = ai.ResetBytes(nil, next)          the next message, reusing the AVPs as well as the index
+//
+// Lookups, compiled queries and plans work the same on lazily decoded messages.  Lazily decoded AVPs may be used
+// from several goroutines, their first decode is serialized by a mutex per message.
+
+// lazily decoded message, shared by its AVPs
+type lazyMessage struct {
+	mu   sync.Mutex
+	dict *dictionary.Dictionary
+}
+
+// decode avp's value, if no other goroutine has yet
+func (l *lazyMessage) decode(avp *AVP) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if avp.decoded != 0 {
+		return
+	}
+	t := dictionary.OctetString
+	if def := l.dict.AVP(avp.VendorCode, avp.AttributeCode); def != nil {
+		t = def.Type
+	}
+	dec := newDecoder(t)
+	if avp.decodeErr = dec.decode(avp.Data); avp.decodeErr == nil {
+		avp.DecodedValue = dec.String()
+	}
+	avp.decoder = dec
+	atomic.StoreUint32(&avp.decoded, 1)
+}
+
+// Decode a Diameter message like Decode, but decode AVP values only when first asked for (GetDecoder, and so every
+// typed getter).  b is not copied: the AVPs' Data shares it, so b must not be modified while the message is in use.
+// Until an AVP's value is decoded its DecodedValue is empty; the other fields are set here.  Lengths that don't add
+// up are an error as with Decode.
+func DecodeLazy(d *dictionary.Dictionary, b []byte) (*Diameter, error) {
+	return new(lazyStore).decode(d, b)
+}
+
+// An indexer over the raw message b, decoding AVP values only when a getter asks for them, see DecodeLazy.  Names
+// in queries and enumerated values are resolved through d, nil for dictionary.Default().
+func NewLazyAvpIndexer(d *dictionary.Dictionary, b []byte) (AvpIndexer, error) {
+	var ai AvpIndexer
+	err := ai.ResetBytes(d, b)
+	return ai, err
+}
+
+// Re-index ai for the raw message b like NewLazyAvpIndexer, reusing the storage of ai's previous index, its AVPs
+// included; see Reset for what that means for indexers and AVPs from the previous message, and for a dictionary
+// set with WithDictionary.  On error ai is left empty.
+func (ai *AvpIndexer) ResetBytes(d *dictionary.Dictionary, b []byte) error {
+	if ai.ix == nil {
+		ai.ix = &avpIndex{}
+	}
+	ix := ai.ix
+	if ix.lazy == nil {
+		ix.lazy = &lazyStore{}
+	}
+	msg, err := ix.lazy.decode(d, b)
+	var avps []*AVP
+	if err == nil {
+		avps = msg.AVPs
+	}
+	ix.reset(avps)
+	ix.dict = d
+	ai.gen = ix.gen
+	return err
+}
+
+// storage of a lazily decoded message; an indexer keeps it across ResetBytes
+type lazyStore struct {
+	msg    Diameter
+	lazy   lazyMessage
+	frames []lazyFrame
+	avps   []AVP
+	ptrs   []*AVP
+}
+
+// dictionary definition and number of sub-AVPs of each AVP, in message order
+type lazyFrame struct {
+	def  *dictionary.AVP
+	subs int
+}
+
+func (s *lazyStore) decode(d *dictionary.Dictionary, b []byte) (*Diameter, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	s.clear()
+	if err := decodeHeader(&s.msg, b); err != nil {
+		return nil, err
+	}
+	data := b[diameterHeaderLen:s.msg.MessageLen:s.msg.MessageLen]
+
+	// framing is checked, and the dictionary consulted, before anything is allocated for the AVPs
+	n, err := s.frame(d, data, diameterHeaderLen)
+	if err != nil {
+		return nil, err
+	}
+	s.lazy = lazyMessage{dict: d}
+	s.msg.dict = d
+	s.avps = resize(s.avps, len(s.frames))
+	s.ptrs = resize(s.ptrs, len(s.frames))
+	l := lazyBuilder{lazy: &s.lazy, frames: s.frames, avps: s.avps, ptrs: s.ptrs}
+	s.msg.AVPs = l.list(data, n)
+	return &s.msg, nil
+}
+
+// drop the previous message; AVPs are zeroed so that they don't keep its buffer, nor their decoded values
+func (s *lazyStore) clear() {
+	for i := range s.avps {
+		s.avps[i] = AVP{}
+	}
+	for i := range s.ptrs {
+		s.ptrs[i] = nil
+	}
+	s.avps, s.ptrs, s.frames = s.avps[:0], s.ptrs[:0], s.frames[:0]
+	s.msg = Diameter{}
+}
+
+// append the frames of the AVPs in b, which starts at offset in the message; returns how many AVPs b holds at its
+// top level
+func (s *lazyStore) frame(d *dictionary.Dictionary, b []byte, offset int) (int, error) {
+	var n int
+	for ; len(b) > 0; n++ {
+		var avp AVP
+		padded, err := decodeAvpHeader(&avp, b, offset)
+		if err != nil {
+			return 0, err
+		}
+		i := len(s.frames)
+		def := d.AVP(avp.VendorCode, avp.AttributeCode)
+		s.frames = append(s.frames, lazyFrame{def: def})
+		if def != nil && def.Type == dictionary.Grouped {
+			subs, err := s.frame(d, avp.Data, offset+int(avp.HeaderLen))
+			if err != nil {
+				return 0, err
+			}
+			s.frames[i].subs = subs
+		}
+		b = b[padded:]
+		offset += padded
+	}
+	return n, nil
+}
+
+// second pass of DecodeLazy, filling the slabs in the order frame saw the AVPs
+type lazyBuilder struct {
+	lazy   *lazyMessage
+	frames []lazyFrame
+	avps   []AVP
+	ptrs   []*AVP
+}
+
+// the n AVPs in b, already checked by frame
+func (l *lazyBuilder) list(b []byte, n int) []*AVP {
+	if n == 0 {
+		return nil
+	}
+	list := l.ptrs[:n:n]
+	l.ptrs = l.ptrs[n:]
+	for i := range list {
+		avp, f := &l.avps[0], l.frames[0]
+		l.avps, l.frames = l.avps[1:], l.frames[1:]
+		padded, _ := decodeAvpHeader(avp, b, 0)
+		t := dictionary.OctetString
+		if f.def != nil {
+			t = f.def.Type
+			avp.AttributeName = f.def.Name
+		}
+		avp.AttributeFormat = t.String()
+		avp.dict = l.lazy.dict
+		if t == dictionary.Grouped {
+			avp.decoder = &DiameterGrouped{}
+			avp.decoded = 1
+			avp.Grouped = l.list(avp.Data, f.subs)
+		} else {
+			avp.lazy = l.lazy
+		}
+		list[i] = avp
+		b = b[padded:]
+	}
+	return list
+}
@@ -0,0 +1,218 @@
+package avpindexer
+
+import (
+	"bytes"
+	"fmt"
+	a "gotest.tools/assert"
+	"sync"
+	"testing"
+)
+
+func encodedLargeMessage(t testing.TB, n int) []byte {
+	b, err := Encode(largeMessage(t, n))
+	a.NilError(t, err)
+	return b
+}
+
+// check that lazy, once decoded, matches eager
+func compareLazy(t *testing.T, lazy, eager []*AVP) {
+	a.Equal(t, len(lazy), len(eager))
+	for i, l := range lazy {
+		e := eager[i]
+		a.Equal(t, l.AttributeCode, e.AttributeCode)
+		a.Equal(t, l.VendorCode, e.VendorCode)
+		a.Equal(t, l.AttributeName, e.AttributeName)
+		a.Equal(t, l.AttributeFormat, e.AttributeFormat)
+		a.Equal(t, l.Flags, e.Flags)
+		a.Equal(t, l.Len, e.Len)
+		a.Equal(t, l.HeaderLen, e.HeaderLen)
+		a.Equal(t, l.Padding, e.Padding)
+		a.Assert(t, bytes.Equal(l.Data, e.Data))
+		a.Equal(t, l.GetDecoder().String(), e.GetDecoder().String())
+		a.Equal(t, l.DecodedValue, e.DecodedValue)
+		a.Equal(t, l.decodeErr == nil, e.decodeErr == nil)
+		compareLazy(t, l.Grouped, e.Grouped)
+	}
+}
+
+// number of AVPs whose value has been decoded
+func countDecoded(avps []*AVP) int {
+	var n int
+	for _, avp := range avps {
+		if avp.lazy != nil && avp.decoded != 0 {
+			n++
+		}
+		n += countDecoded(avp.Grouped)
+	}
+	return n
+}
+
+func TestDecodeLazy(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"small": testPacketDiameterAccountingRequest271,
+		"large": encodedLargeMessage(t, 20),
+	} {
+		eager, err := Decode(nil, b)
+		a.NilError(t, err)
+		lazy, err := DecodeLazy(nil, b)
+		a.NilError(t, err, name)
+		a.Equal(t, lazy.CommandCode, eager.CommandCode)
+		a.Equal(t, lazy.MessageLen, eager.MessageLen)
+		a.Equal(t, countDecoded(lazy.AVPs), 0, name)
+		a.Equal(t, lazy.AVPs[0].DecodedValue, "", name)
+		compareLazy(t, lazy.AVPs, eager.AVPs)
+	}
+
+	// the data isn't copied
+	b := append([]byte(nil), testPacketDiameterAccountingRequest271...)
+	lazy, err := DecodeLazy(nil, b)
+	a.NilError(t, err)
+	sid := lazy.AVPs[0]
+	a.Equal(t, sid.AttributeName, "Session-Id")
+	b[diameterHeaderLen+int(sid.HeaderLen)] = 'X'
+	a.Equal(t, sid.GetDecoder().String()[0], byte('X'))
+
+	// empty groups and no AVPs at all
+	b, err = NewMessage(271, 3, true).Group(0, 873, func(g *GroupBuilder) {}).Bytes()
+	a.NilError(t, err)
+	lazy, err = DecodeLazy(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, len(lazy.AVPs), 1)
+	a.Assert(t, lazy.AVPs[0].Grouped == nil)
+	b, err = NewMessage(271, 3, true).Bytes()
+	a.NilError(t, err)
+	lazy, err = DecodeLazy(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, len(lazy.AVPs), 0)
+}
+
+func TestLazyAvpIndexer(t *testing.T) {
+	b := encodedLargeMessage(t, 60)
+	eager := NewAvpIndexer(largeMessage(t, 60))
+	dia, err := DecodeLazy(nil, b)
+	a.NilError(t, err)
+	ai := NewAvpIndexer(dia)
+
+	// only what's asked for is decoded
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	a.Equal(t, rg.GetUint32(ai), uint32(100))
+	a.Equal(t, ai.GetUTF8String(0, 264), "pcef.example.com")
+	a.Equal(t, qNodeAddress.GetIPAddress(ai).String(), "78.147.12.161")
+	a.Equal(t, rg.Count(ai), 60)
+	a.Equal(t, ai.FromGroup(0, 456).Count(Wildcard, Wildcard), eager.FromGroup(0, 456).Count(Wildcard, Wildcard))
+	a.Equal(t, countDecoded(dia.AVPs), 3)
+
+	a.DeepEqual(t, rg.AppendUint32(ai, nil), rg.AppendUint32(eager, nil))
+	a.DeepEqual(t, ai.GetAllUint64(Wildcard, 412), eager.GetAllUint64(Wildcard, 412))
+	a.Equal(t, countDecoded(dia.AVPs), 122)
+	a.DeepEqual(t, ai.GetValue(0, 416), eager.GetValue(0, 416))
+	r := NewPlan(rg, qNodeAddress).Run(dia, nil)
+	a.Equal(t, r.GetUint32(0), uint32(100))
+	a.Equal(t, r.Count(0), 60)
+
+	// type mismatches and bad data are reported as with Decode
+	_, err = ai.GetUint32E(0, 264)
+	a.ErrorContains(t, err, "Origin-Host is DiameterIdentity")
+	li, err := NewLazyAvpIndexer(nil, b)
+	a.NilError(t, err)
+	a.Equal(t, li.GetUTF8String(0, 264), "pcef.example.com")
+	_, err = NewLazyAvpIndexer(nil, b[:len(b)-1])
+	a.ErrorContains(t, err, "malformed")
+}
+
+func TestLazyResetBytes(t *testing.T) {
+	small, large := testPacketDiameterAccountingRequest271, encodedLargeMessage(t, 60)
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	var ai AvpIndexer
+	for i, b := range [][]byte{large, small, large[:30], large, small} {
+		err := ai.ResetBytes(nil, b)
+		if i == 2 {
+			a.ErrorContains(t, err, "malformed")
+			a.Equal(t, ai.Count(Wildcard, Wildcard), 0)
+			continue
+		}
+		a.NilError(t, err)
+		eager, err := Decode(nil, b)
+		a.NilError(t, err)
+		ei := NewAvpIndexer(eager)
+		a.Equal(t, ai.Count(Wildcard, Wildcard), ei.Count(Wildcard, Wildcard))
+		a.DeepEqual(t, rg.AppendUint32(ai, nil), rg.AppendUint32(ei, nil))
+		a.Equal(t, qSessionId.GetUTF8String(ai), qSessionId.GetUTF8String(ei))
+		a.Equal(t, countDecoded(ai.ix.lazy.msg.AVPs), 1+len(rg.AppendUint32(ei, nil)))
+	}
+
+	// an eager message drops the lazy one's AVPs
+	ai.Reset(d)
+	for _, avp := range ai.ix.lazy.avps[:cap(ai.ix.lazy.avps)] {
+		a.Assert(t, avp.Data == nil)
+	}
+	a.Equal(t, qTimeUsage.GetUint32(ai), uint32(241))
+
+	// once warmed up, only the values read allocate: a decoder and DecodedValue for the large message's Rating-Group
+	a.NilError(t, ai.ResetBytes(nil, large))
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, b := range [][]byte{small, large} {
+			if err := ai.ResetBytes(nil, b); err != nil {
+				t.Fatal(err)
+			}
+			qRecordType.Exists(ai)
+			rg.Count(ai)
+			rg.GetUint32(ai)
+		}
+	})
+	a.Equal(t, allocs, float64(2))
+}
+
+func TestLazyConcurrent(t *testing.T) {
+	ai, err := NewLazyAvpIndexer(nil, encodedLargeMessage(t, 60))
+	a.NilError(t, err)
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vs := rg.AppendUint32(ai, nil)
+			if len(vs) != 60 || vs[59] != 159 || ai.GetUTF8String(0, 263) != "pcef.example.com;1;2" {
+				t.Error("wrong result")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkLazyDecode(b *testing.B) {
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	sid := MustCompile("/Session-Id")
+	for _, n := range []int{1, 60} {
+		pkt := encodedLargeMessage(b, n)
+		// 3 values out of the message
+		extract := func(ai AvpIndexer) {
+			rg.GetUint32(ai)
+			sid.GetUTF8String(ai)
+			qNodeAddress.GetIPAddress(ai)
+		}
+		b.Run(fmt.Sprintf("%d/Decode", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dia, _ := Decode(nil, pkt)
+				extract(NewAvpIndexer(dia))
+			}
+		})
+		b.Run(fmt.Sprintf("%d/lazy", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ai, _ := NewLazyAvpIndexer(nil, pkt)
+				extract(ai)
+			}
+		})
+		b.Run(fmt.Sprintf("%d/lazy/reset", n), func(b *testing.B) {
+			var ai AvpIndexer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ai.ResetBytes(nil, pkt)
+				extract(ai)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"time"
+)
+
+// Typed retrieval that reports why a value is unavailable, instead of returning the zero value like the Get* methods
+// do.  LookupXxx returns an ok flag, GetXxxE returns an *AvpError.
+
+// Kinds of AvpError; test with errors.Is(err, ErrAvpNotFound) etc.
+var (
+	ErrAvpNotFound     = errors.New("avp not found")
+	ErrAvpTypeMismatch = errors.New("avp type mismatch")
+	ErrAvpDecode       = errors.New("avp decode failure")
+)
+
+// Error from a typed retrieval.  Kind is one of ErrAvpNotFound, ErrAvpTypeMismatch or ErrAvpDecode.
+type AvpError struct {
+	Kind   error
+	Path   string // path that was looked up, in ParsePath form
+	Avp    *AVP   // AVP that was found, nil if not found
+	Wanted string // decoder type that was asked for
+	Err    error  // underlying cause, if any
+}
+
+func (e *AvpError) Error() string {
+	s := fmt.Sprintf("%s: %s", e.Path, e.Kind)
+	if e.Avp != nil && e.Kind == ErrAvpTypeMismatch {
+		s += fmt.Sprintf(" (%s is %s, wanted %s)", e.Avp.AttributeName, e.Avp.AttributeFormat, e.Wanted)
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *AvpError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *AvpError) Unwrap() error {
+	return e.Err
+}
+
+// Diameter decoder type D whose values are Vs, e.g. *DiameterUnsigned32 and uint32
+type valueDecoder[V any] interface {
+	DiameterDecoder
+	Get() V
+}
+
+// first AVP matching path whose decoder is a D, or nil.  AVPs of other types are skipped, so that a wildcard or
+// '**' that also matches some other AVP first still finds the one asked for; mismatch is the first of those.
+func firstOfType[D DiameterDecoder](ai AvpIndexer, path *pathElement) (avp, mismatch *AVP) {
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		if isType[D](pe.avp) {
+			avp = pe.avp
+			return false
+		}
+		if mismatch == nil {
+			mismatch = pe.avp
+		}
+		return true
+	})
+	return avp, mismatch
+}
+
+// whether avp's decoder is a D, whether its data decoded or not
+func isType[D DiameterDecoder](avp *AVP) bool {
+	_, ok := avp.GetDecoder().(D)
+	return ok
+}
+
+// value of the first AVP matching path that is a D; false if there is none or its data didn't decode
+func lookupValue[D valueDecoder[V], V any](ai AvpIndexer, path *pathElement) (V, bool) {
+	avp, _ := firstOfType[D](ai, path)
+	return lookupAvp[D, V](avp)
+}
+
+// value of avp if it is a D whose data decoded; false if not, or avp is nil
+func lookupAvp[D valueDecoder[V], V any](avp *AVP) (V, bool) {
+	if dec, ok := decoderAs[D](avp); ok {
+		return dec.Get(), true
+	}
+	var zero V
+	return zero, false
+}
+
+// same as lookupValue, without the flag
+func getValue[D valueDecoder[V], V any](ai AvpIndexer, path *pathElement) V {
+	v, _ := lookupValue[D, V](ai, path)
+	return v
+}
+
+// value of the first AVP matching path that is a D, or an *AvpError saying why there is none
+func valueE[D valueDecoder[V], V any](ai AvpIndexer, path *pathElement) (V, error) {
+	avp, mismatch := firstOfType[D](ai, path)
+	if avp == nil {
+		avp = mismatch
+	}
+	return valueOf[D, V](path, avp)
+}
+
+// value of avp if it is a D whose data decoded, else an *AvpError saying why not
+func valueOf[D valueDecoder[V], V any](path *pathElement, avp *AVP) (V, error) {
+	if dec, ok := decoderAs[D](avp); ok {
+		return dec.Get(), nil
+	}
+	var zero V
+	var want D
+	ae := &AvpError{
+		Kind:   ErrAvpNotFound,
+		Path:   Path{leaf: path}.String(),
+		Avp:    avp,
+		Wanted: reflect.TypeOf(want).Elem().Name(),
+	}
+	if avp != nil {
+		ae.Kind = ErrAvpTypeMismatch
+		dec := avp.GetDecoder()
+		if dec == nil || reflect.ValueOf(dec).IsNil() {
+			ae.Kind, ae.Err = ErrAvpDecode, errors.New("no decoder")
+		} else if _, ok := dec.(D); ok {
+			ae.Kind, ae.Err = ErrAvpDecode, avp.decodeErr
+		}
+	}
+	return zero, ae
+}
+
+// first AVP matching path in message order, or nil
+func (ai AvpIndexer) firstAvp(path *pathElement) *AVP {
+	var avp *AVP
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		avp = pe.avp
+		return false
+	})
+	return avp
+}
+
+// run get, a GetXxxE method of Query, for q; the path parse error if there was one
+func queryE[V any](q avpQuery, get func(Query, Indexer) (V, error)) (V, error) {
+	if q.err != nil {
+		var zero V
+		return zero, q.err
+	}
+	return get(q.compiled(), q.ai)
+}
+
+// retrieve first matching uint32 value with given id; false if there is no such AVP or it can't be read as uint32
+func (ai AvpIndexer) LookupUint32(vendorId, attrId uint32) (uint32, bool) {
+	return lookupValue[*DiameterUnsigned32, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching uint32 value with given id; false if there is no such AVP or it can't be read as uint32
+func (aip avpIndexerWithPath) LookupUint32(vendorId, attrId uint32) (uint32, bool) {
+	return lookupValue[*DiameterUnsigned32, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching uint32 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetUint32E(vendorId, attrId uint32) (uint32, error) {
+	return valueE[*DiameterUnsigned32, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching uint32 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetUint32E(vendorId, attrId uint32) (uint32, error) {
+	return valueE[*DiameterUnsigned32, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching uint32 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetUint32E() (uint32, error) {
+	return queryE(q, Query.GetUint32E)
+}
+
+// retrieve first matching enumerated (uint32) value with given id; false if there is no such AVP or it can't be
+// read as enumerated
+func (ai AvpIndexer) LookupEnumerated(vendorId, attrId uint32) (uint32, bool) {
+	return lookupValue[*DiameterEnumerated, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching enumerated (uint32) value with given id; false if there is no such AVP or it can't be
+// read as enumerated
+func (aip avpIndexerWithPath) LookupEnumerated(vendorId, attrId uint32) (uint32, bool) {
+	return lookupValue[*DiameterEnumerated, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching enumerated (uint32) value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetEnumeratedE(vendorId, attrId uint32) (uint32, error) {
+	return valueE[*DiameterEnumerated, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching enumerated (uint32) value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetEnumeratedE(vendorId, attrId uint32) (uint32, error) {
+	return valueE[*DiameterEnumerated, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching enumerated (uint32) value, or an *AvpError (or the path parse error) and the zero value
+func (q avpQuery) GetEnumeratedE() (uint32, error) {
+	return queryE(q, Query.GetEnumeratedE)
+}
+
+// retrieve first matching uint64 value with given id; false if there is no such AVP or it can't be read as uint64
+func (ai AvpIndexer) LookupUint64(vendorId, attrId uint32) (uint64, bool) {
+	return lookupValue[*DiameterUnsigned64, uint64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching uint64 value with given id; false if there is no such AVP or it can't be read as uint64
+func (aip avpIndexerWithPath) LookupUint64(vendorId, attrId uint32) (uint64, bool) {
+	return lookupValue[*DiameterUnsigned64, uint64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching uint64 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetUint64E(vendorId, attrId uint32) (uint64, error) {
+	return valueE[*DiameterUnsigned64, uint64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching uint64 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetUint64E(vendorId, attrId uint32) (uint64, error) {
+	return valueE[*DiameterUnsigned64, uint64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching uint64 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetUint64E() (uint64, error) {
+	return queryE(q, Query.GetUint64E)
+}
+
+// retrieve first matching int32 value with given id; false if there is no such AVP or it can't be read as int32
+func (ai AvpIndexer) LookupInt32(vendorId, attrId uint32) (int32, bool) {
+	return lookupValue[*DiameterInteger32, int32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching int32 value with given id; false if there is no such AVP or it can't be read as int32
+func (aip avpIndexerWithPath) LookupInt32(vendorId, attrId uint32) (int32, bool) {
+	return lookupValue[*DiameterInteger32, int32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching int32 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetInt32E(vendorId, attrId uint32) (int32, error) {
+	return valueE[*DiameterInteger32, int32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching int32 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetInt32E(vendorId, attrId uint32) (int32, error) {
+	return valueE[*DiameterInteger32, int32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching int32 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetInt32E() (int32, error) {
+	return queryE(q, Query.GetInt32E)
+}
+
+// retrieve first matching int64 value with given id; false if there is no such AVP or it can't be read as int64
+func (ai AvpIndexer) LookupInt64(vendorId, attrId uint32) (int64, bool) {
+	return lookupValue[*DiameterInteger64, int64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching int64 value with given id; false if there is no such AVP or it can't be read as int64
+func (aip avpIndexerWithPath) LookupInt64(vendorId, attrId uint32) (int64, bool) {
+	return lookupValue[*DiameterInteger64, int64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching int64 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetInt64E(vendorId, attrId uint32) (int64, error) {
+	return valueE[*DiameterInteger64, int64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching int64 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetInt64E(vendorId, attrId uint32) (int64, error) {
+	return valueE[*DiameterInteger64, int64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching int64 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetInt64E() (int64, error) {
+	return queryE(q, Query.GetInt64E)
+}
+
+// retrieve first matching float32 value with given id; false if there is no such AVP or it can't be read as float32
+func (ai AvpIndexer) LookupFloat32(vendorId, attrId uint32) (float32, bool) {
+	return lookupValue[*DiameterFloat32, float32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching float32 value with given id; false if there is no such AVP or it can't be read as float32
+func (aip avpIndexerWithPath) LookupFloat32(vendorId, attrId uint32) (float32, bool) {
+	return lookupValue[*DiameterFloat32, float32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching float32 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetFloat32E(vendorId, attrId uint32) (float32, error) {
+	return valueE[*DiameterFloat32, float32](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching float32 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetFloat32E(vendorId, attrId uint32) (float32, error) {
+	return valueE[*DiameterFloat32, float32](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching float32 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetFloat32E() (float32, error) {
+	return queryE(q, Query.GetFloat32E)
+}
+
+// retrieve first matching float64 value with given id; false if there is no such AVP or it can't be read as float64
+func (ai AvpIndexer) LookupFloat64(vendorId, attrId uint32) (float64, bool) {
+	return lookupValue[*DiameterFloat64, float64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching float64 value with given id; false if there is no such AVP or it can't be read as float64
+func (aip avpIndexerWithPath) LookupFloat64(vendorId, attrId uint32) (float64, bool) {
+	return lookupValue[*DiameterFloat64, float64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching float64 value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetFloat64E(vendorId, attrId uint32) (float64, error) {
+	return valueE[*DiameterFloat64, float64](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching float64 value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetFloat64E(vendorId, attrId uint32) (float64, error) {
+	return valueE[*DiameterFloat64, float64](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching float64 value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetFloat64E() (float64, error) {
+	return queryE(q, Query.GetFloat64E)
+}
+
+// retrieve first matching time.Time value with given id; false if there is no such AVP or it can't be read as
+// time.Time
+func (ai AvpIndexer) LookupTime(vendorId, attrId uint32) (time.Time, bool) {
+	return lookupValue[*DiameterTime, time.Time](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching time.Time value with given id; false if there is no such AVP or it can't be read as
+// time.Time
+func (aip avpIndexerWithPath) LookupTime(vendorId, attrId uint32) (time.Time, bool) {
+	return lookupValue[*DiameterTime, time.Time](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching time.Time value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetTimeE(vendorId, attrId uint32) (time.Time, error) {
+	return valueE[*DiameterTime, time.Time](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching time.Time value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetTimeE(vendorId, attrId uint32) (time.Time, error) {
+	return valueE[*DiameterTime, time.Time](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching time.Time value, or an *AvpError (or the path parse error) and the zero value for that
+// type
+func (q avpQuery) GetTimeE() (time.Time, error) {
+	return queryE(q, Query.GetTimeE)
+}
+
+// retrieve first matching string value with given id; false if there is no such AVP or it can't be read as string
+func (ai AvpIndexer) LookupUTF8String(vendorId, attrId uint32) (string, bool) {
+	return lookupValue[*DiameterOctetString, string](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching string value with given id; false if there is no such AVP or it can't be read as string
+func (aip avpIndexerWithPath) LookupUTF8String(vendorId, attrId uint32) (string, bool) {
+	return lookupValue[*DiameterOctetString, string](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching string value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetUTF8StringE(vendorId, attrId uint32) (string, error) {
+	return valueE[*DiameterOctetString, string](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching string value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetUTF8StringE(vendorId, attrId uint32) (string, error) {
+	return valueE[*DiameterOctetString, string](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching string value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetUTF8StringE() (string, error) {
+	return queryE(q, Query.GetUTF8StringE)
+}
+
+// retrieve first matching net.IP value with given id; false if there is no such AVP or it can't be read as net.IP
+func (ai AvpIndexer) LookupIPAddress(vendorId, attrId uint32) (net.IP, bool) {
+	return lookupValue[*DiameterIPAddress, net.IP](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching net.IP value with given id; false if there is no such AVP or it can't be read as net.IP
+func (aip avpIndexerWithPath) LookupIPAddress(vendorId, attrId uint32) (net.IP, bool) {
+	return lookupValue[*DiameterIPAddress, net.IP](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching net.IP value with given id, or an *AvpError and the zero value for that type
+func (ai AvpIndexer) GetIPAddressE(vendorId, attrId uint32) (net.IP, error) {
+	return valueE[*DiameterIPAddress, net.IP](ai.at(vendorId, attrId))
+}
+
+// retrieve first matching net.IP value with given id, or an *AvpError and the zero value for that type
+func (aip avpIndexerWithPath) GetIPAddressE(vendorId, attrId uint32) (net.IP, error) {
+	return valueE[*DiameterIPAddress, net.IP](aip.at(vendorId, attrId))
+}
+
+// retrieve first matching net.IP value, or an *AvpError (or the path parse error) and the zero value for that type
+func (q avpQuery) GetIPAddressE() (net.IP, error) {
+	return queryE(q, Query.GetIPAddressE)
+}
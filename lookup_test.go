@@ -0,0 +1,126 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	v, ok := ai.LookupUint32(0, 485)
+	a.Assert(t, ok)
+	a.Equal(t, v, uint32(1))
+
+	v, ok = ai.LookupUint32(132123, 999998)
+	a.Assert(t, !ok)
+	a.Equal(t, v, uint32(0))
+
+	// Subscription-Id-Type is present and 0 (END_USER_E164)
+	v, ok = ai.FromGroup(0, 443).LookupEnumerated(0, 450)
+	a.Assert(t, ok)
+	a.Equal(t, v, uint32(0))
+
+	v64, ok := MustCompile("10415/2040/0/364").LookupUint64(ai)
+	a.Assert(t, ok)
+	a.Equal(t, v64, uint64(3208))
+
+	// wrong type doesn't panic
+	_, ok = ai.LookupUint64(0, 485)
+	a.Assert(t, !ok)
+}
+
+func TestGetE(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	v, err := ai.GetUint32E(10415, 2045)
+	a.NilError(t, err)
+	a.Equal(t, v, uint32(241))
+
+	_, err = ai.FromGroup(10415, 874).GetUint32E(10415, 2045)
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+	a.Equal(t, err.Error(), "10415/874/10415/2045: avp not found")
+
+	s, err := ai.GetUTF8StringE(0, 485)
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+	a.Equal(t, s, "")
+	var ae *AvpError
+	a.Assert(t, errors.As(err, &ae))
+	a.Equal(t, ae.Avp.AttributeCode, uint32(485))
+	a.Equal(t, ae.Wanted, "DiameterOctetString")
+
+	// grouped AVP is not a scalar
+	_, err = ai.GetUint32E(10415, 873)
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+
+	_, err = ai.Query("10415/x").GetTimeE()
+	a.ErrorContains(t, err, "invalid avp path")
+
+	tm, err := ai.Query("10415/2040/10415/2043").GetTimeE()
+	a.NilError(t, err)
+	a.Equal(t, tm, ai.FromGroup(10415, 2040).GetTime(10415, 2043))
+
+	// matches of other types are passed over, and only reported if there is nothing else
+	v, err = ai.AtRoot().GetUint32E(0, Wildcard) // Session-Id, Origin-Host ... come first
+	a.NilError(t, err)
+	a.Equal(t, v, ai.GetUint32(0, 485))
+	v, err = GetE[uint32](ai.AtRoot(), 0, Wildcard) // reads Enumerated too
+	a.NilError(t, err)
+	a.Equal(t, v, ai.GetEnumerated(0, 480))
+	_, err = ai.AtRoot().GetFloat64E(0, Wildcard)
+	a.Assert(t, errors.As(err, &ae))
+	a.Equal(t, ae.Avp.AttributeCode, uint32(263))
+	_, err = GetE[float64](ai.AtRoot(), 0, Wildcard)
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+}
+
+func TestDecodeFailure(t *testing.T) {
+	avp := &AVP{AttributeCode: 485, Data: []byte{0, 1}}
+	ai := NewAvpIndexer(&Diameter{AVPs: []*AVP{avp}})
+
+	_, err := ai.GetUint32E(0, 485)
+	a.Assert(t, errors.Is(err, ErrAvpDecode))
+}
+
+func TestDecodeFailureReported(t *testing.T) {
+	var avps []byte
+	avps = append(avps, testAvp(t, 257, 0x40, 0, []byte{0, 1, 10, 0, 0})...) // Host-IP-Address, 5 bytes
+	avps = append(avps, testAvp(t, 25, 0x40, 0, []byte{0xff, 0, 0xfe})...)   // Class, any bytes are an OctetString
+	b, err := appendMessage(nil, 1, 0, 271, 3, 1, 2, avps)
+	a.NilError(t, err)
+	eager, err := Decode(nil, b)
+	a.NilError(t, err)
+	lazy, err := NewLazyAvpIndexer(nil, b)
+	a.NilError(t, err)
+	host, class := MustCompile("Host-IP-Address"), MustCompile("Class")
+
+	for name, ai := range map[string]AvpIndexer{"eager": NewAvpIndexer(eager), "lazy": lazy} {
+		ip, err := ai.GetIPAddressE(0, 257)
+		a.Assert(t, errors.Is(err, ErrAvpDecode), name)
+		a.ErrorContains(t, err, "data length 5", name)
+		a.Assert(t, ip == nil, name)
+		_, ok := ai.LookupIPAddress(0, 257)
+		a.Assert(t, !ok, name)
+		_, ok = host.LookupIPAddress(ai)
+		a.Assert(t, !ok, name)
+		_, err = host.GetIPAddressE(ai)
+		a.Assert(t, errors.Is(err, ErrAvpDecode), name)
+		_, err = GetE[net.IP](ai, 0, 257)
+		a.Assert(t, errors.Is(err, ErrAvpDecode), name)
+		a.Equal(t, len(ai.GetAllIPAddress(0, 257)), 0, name)
+		_, err = ai.GetValueE(0, 257)
+		a.Assert(t, errors.Is(err, ErrAvpDecode), name)
+
+		s, err := ai.GetUTF8StringE(0, 25)
+		a.NilError(t, err, name)
+		a.Equal(t, s, "\xff\x00\xfe", name)
+		a.Equal(t, class.GetUTF8String(ai), s, name)
+	}
+
+	r := NewPlan(host, class).Run(eager, nil)
+	_, ok := r.LookupIPAddress(0)
+	a.Assert(t, !ok)
+	a.Equal(t, r.GetUTF8String(1), "\xff\x00\xfe")
+}
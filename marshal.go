@@ -0,0 +1,228 @@
+package avpindexer
+
+import (
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"reflect"
+)
+
+// Struct marshalling, the reverse of Unmarshal: a struct with avp tags becomes a message, for test harnesses and
+// stubs answering requests.
+//
+//	type cca struct {
+//		SessionId  string   `avp:"Session-Id"`
+//		ResultCode uint32   `avp:"Result-Code"`
+//		MSCC       []struct {
+//			RatingGroup uint32  `avp:"Rating-Group"`
+//			Granted     *uint64 `avp:"Granted-Service-Unit/CC-Total-Octets"`
+//		} `avp:"Multiple-Services-Credit-Control"`
+//	}
+//	dia, err := Marshal(Header{CommandCode: 272, ApplicationID: 4}, cca{...})
+//
+// Fields are encoded in struct order, as for Unmarshal: nested structs become grouped AVPs, slices repeated AVPs,
+// nil pointers and slices are left out.  A tag with several elements puts the AVP into the groups named before it,
+// created as needed and shared by fields with the same prefix.  `avp:"path,omitempty"` leaves out zero values too.
+// Paths can't contain wildcards or '**'.  Values are encoded by their AVP's dictionary type (see encode.go), with
+// the M and P flags the dictionary requires and V for vendor specific AVPs; strings are taken as enumeration names
+// for Enumerated AVPs.
+
+// Header of a marshalled message.
+type Header struct {
+	CommandCode   uint32
+	ApplicationID uint32
+	Flags         uint8 // FlagRequest etc
+	HopByHopID    uint32
+	EndToEndID    uint32
+
+	Dictionary *dictionary.Dictionary // for AVP names, types and flags; nil means dictionary.Default()
+}
+
+// Encode the tagged fields of struct v (or a pointer to one) as the AVPs of a message with header h, see above.
+func MarshalBytes(h Header, v interface{}) ([]byte, error) {
+	d := h.Dictionary
+	if d == nil {
+		d = dictionary.Default()
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Marshal: need a struct or a pointer to one, got %T", v)
+	}
+	root := &avpNode{}
+	if err := marshalStruct(d, root, rv, rv.Type().Name()); err != nil {
+		return nil, fmt.Errorf("Marshal: field %w", err)
+	}
+	avps, err := root.appendChildren(nil)
+	if err != nil {
+		return nil, err
+	}
+	return appendMessage(nil, diameterVersion, h.Flags, h.CommandCode, h.ApplicationID, h.HopByHopID, h.EndToEndID, avps)
+}
+
+// Same as MarshalBytes, decoded into a message as if it were captured.
+func Marshal(h Header, v interface{}) (*Diameter, error) {
+	b, err := MarshalBytes(h, v)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(h.Dictionary, b)
+}
+
+// AVP of a message being marshalled: encoded data, or sub-AVPs if it's grouped
+type avpNode struct {
+	avpId
+	def      *dictionary.AVP
+	data     []byte
+	grouped  bool
+	children []*avpNode
+}
+
+// last grouped child with given id, added if there is none
+func (n *avpNode) group(d *dictionary.Dictionary, id avpId) *avpNode {
+	for i := len(n.children) - 1; i >= 0; i-- {
+		if c := n.children[i]; c.grouped && c.avpId == id {
+			return c
+		}
+	}
+	return n.add(d, id, true)
+}
+
+func (n *avpNode) add(d *dictionary.Dictionary, id avpId, grouped bool) *avpNode {
+	c := &avpNode{avpId: id, def: d.AVP(id.vendorId, id.attrId), grouped: grouped}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *avpNode) appendChildren(b []byte) ([]byte, error) {
+	var err error
+	for _, c := range n.children {
+		data := c.data
+		if c.grouped {
+			if data, err = c.appendChildren(nil); err != nil {
+				return nil, err
+			}
+		}
+		if b, err = appendAvp(b, c.attrId, avpFlags(c.def, c.vendorId), c.vendorId, data); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func marshalStruct(d *dictionary.Dictionary, n *avpNode, sv reflect.Value, name string) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		tag, ok := f.Tag.Lookup("avp")
+		if !ok || tag == "-" {
+			continue
+		}
+		fname := name + "." + f.Name
+		if !f.IsExported() {
+			return fmt.Errorf("%s is not exported", fname)
+		}
+		path, omitEmpty, err := splitTag(tag)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+		ids, err := marshalPath(d, path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+		fv := sv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		g := n
+		for _, id := range ids[:len(ids)-1] {
+			g = g.group(d, id)
+		}
+		if err := marshalField(d, g, ids[len(ids)-1], fv, fname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalField(d *dictionary.Dictionary, n *avpNode, id avpId, fv reflect.Value, fname string) error {
+	ft := fv.Type()
+	switch {
+	case isScalarType(ft):
+		if ft.Kind() == reflect.Slice && fv.IsNil() {
+			return nil
+		}
+		c := n.add(d, id, false)
+		t, ok := avpTypeOf(ft)
+		if c.def != nil {
+			t, ok = c.def.Type, c.def.Type != dictionary.Grouped
+		}
+		if !ok {
+			return fmt.Errorf("%s: no AVP type for %s, %s", fname, ft, avpDesc(c))
+		}
+		if t == dictionary.Enumerated && ft.Kind() == reflect.String && c.def != nil {
+			// enumeration name
+			e, ok := c.def.EnumValue(fv.String())
+			if !ok {
+				return fmt.Errorf("%s: %s has no value %q", fname, avpDesc(c), fv.String())
+			}
+			fv = reflect.ValueOf(e)
+		}
+		data, err := encodeData(t, fv)
+		if err != nil {
+			return fmt.Errorf("%s, %s: %w", fname, avpDesc(c), err)
+		}
+		c.data = data
+
+	case ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Interface:
+		if !fv.IsNil() {
+			return marshalField(d, n, id, fv.Elem(), fname)
+		}
+
+	case ft.Kind() == reflect.Slice:
+		for i := 0; i < fv.Len(); i++ {
+			if err := marshalField(d, n, id, fv.Index(i), fmt.Sprintf("%s[%d]", fname, i)); err != nil {
+				return err
+			}
+		}
+
+	case ft.Kind() == reflect.Struct:
+		c := n.add(d, id, true)
+		if c.def != nil && c.def.Type != dictionary.Grouped {
+			return fmt.Errorf("%s: %s is not Grouped", fname, avpDesc(c))
+		}
+		return marshalStruct(d, c, fv, fname)
+
+	default:
+		return fmt.Errorf("%s: %w: can't encode %s", fname, ErrAvpEncode, ft)
+	}
+	return nil
+}
+
+// ids of the AVPs along a tag path, outermost group first
+func marshalPath(d *dictionary.Dictionary, tag string) ([]avpId, error) {
+	p, err := parseTagPath(d, tag)
+	if err != nil {
+		return nil, err
+	}
+	var ids []avpId
+	for pe := p.leaf; pe != nil; pe = pe.parent {
+		switch {
+		case pe.kind == kindRoot:
+		case pe.kind != kindAvp || pe.hasWildcard():
+			return nil, fmt.Errorf("can't marshal to path %s with wildcards or '**'", p)
+		default:
+			ids = append([]avpId{pe.avpId}, ids...)
+		}
+	}
+	return ids, nil
+}
+
+// AVP name and id for errors
+func avpDesc(n *avpNode) string {
+	if n.def != nil {
+		return fmt.Sprintf("%s (%d/%d)", n.def.Name, n.vendorId, n.attrId)
+	}
+	return fmt.Sprintf("%d/%d", n.vendorId, n.attrId)
+}
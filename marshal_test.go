@@ -0,0 +1,145 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type testCCA struct {
+	SessionId   string     `avp:"Session-Id"`
+	ResultCode  uint32     `avp:"Result-Code"`
+	OriginHost  string     `avp:"Origin-Host"`
+	RequestType Enum       `avp:"CC-Request-Type"`
+	Validity    *uint32    `avp:"Validity-Time"`
+	MSCC        []testMSCC `avp:"Multiple-Services-Credit-Control"`
+	ChargingId  uint32     `avp:"Service-Information/PS-Information/3GPP-Charging-Id"`
+	SGSN        net.IP     `avp:"Service-Information/PS-Information/SGSN-Address"`
+	Private     []byte     `avp:"99/1"`
+}
+
+type testMSCC struct {
+	RatingGroup uint32 `avp:"Rating-Group"`
+	Octets      uint64 `avp:"Used-Service-Unit/CC-Total-Octets"`
+	Seconds     uint32 `avp:"Used-Service-Unit/CC-Time,omitempty"`
+	ResultCode  uint32 `avp:"Result-Code,omitempty"`
+}
+
+func TestMarshal(t *testing.T) {
+	v := testCCA{
+		SessionId:   "sess;1",
+		ResultCode:  2001,
+		OriginHost:  "ocs.example.com",
+		RequestType: Enum{Value: 2},
+		ChargingId:  0x5e9ed913,
+		SGSN:        net.IP{10, 1, 2, 3},
+		Private:     []byte{1, 2, 3},
+		MSCC: []testMSCC{
+			{RatingGroup: 100, Octets: 1 << 20, Seconds: 600},
+			{RatingGroup: 200, Octets: 5000, ResultCode: 4012},
+		},
+	}
+
+	dia, err := Marshal(Header{CommandCode: 272, ApplicationID: 4, HopByHopID: 7, EndToEndID: 8}, &v)
+	a.NilError(t, err)
+	a.Equal(t, dia.CommandCode, uint32(272))
+	a.Equal(t, dia.ApplicationID, uint32(4))
+	a.Equal(t, dia.HopByHopID, uint32(7))
+	a.Equal(t, dia.EndToEndID, uint32(8))
+	a.Equal(t, len(dia.AVPs), 8) // Validity-Time left out
+
+	ai := NewAvpIndexer(dia)
+	a.Equal(t, ai.GetUTF8String(0, 263), "sess;1")
+	a.Equal(t, ai.GetUint32(0, 268), uint32(2001))
+	a.Equal(t, ai.GetEnumeratedName(0, 416), "UPDATE_REQUEST")
+	a.Equal(t, ai.FromGroup(0, 456).FromGroup(0, 446).GetUint64(0, 421), uint64(1<<20))
+	a.Equal(t, ai.FromGroup(0, 456).GetUint32(0, 268), uint32(4012))
+	a.DeepEqual(t, ai.FromGroup(0, 456).FromGroup(0, 446).GetAllUint32(0, 420), []uint32{600})
+	a.Equal(t, ai.Query("10415/873/10415/874/10415/1228").GetIPAddress().String(), "10.1.2.3")
+	a.Equal(t, len(ai.Query("10415/873").Groups()), 1)
+
+	// flags from the dictionary, V with the vendor id
+	a.Equal(t, dia.AVPs[1].Flags, uint8(0x40))
+	si := dia.AVPs[6]
+	a.Equal(t, si.Flags, uint8(0xc0))
+	a.Equal(t, si.VendorCode, uint32(10415))
+	a.Equal(t, dia.AVPs[7].Flags, uint8(0x80))
+
+	// round trip through Unmarshal
+	var back testCCA
+	a.NilError(t, Unmarshal(dia, &back))
+	back.RequestType.Name = ""
+	a.DeepEqual(t, back, v)
+}
+
+func TestMarshalBytes(t *testing.T) {
+	var v struct {
+		Session string `avp:"0/263"`
+		Type    uint32 `avp:"10415/999"`
+	}
+	v.Session = "ab"
+	v.Type = 1
+	b, err := MarshalBytes(Header{CommandCode: 271, Flags: FlagRequest | FlagProxiable, ApplicationID: 3}, v)
+	a.NilError(t, err)
+	a.DeepEqual(t, b, []byte{
+		1, 0, 0, 48, 0xc0, 0, 1, 15, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 1, 7, 0x40, 0, 0, 10, 'a', 'b', 0, 0, // Session-Id, padded
+		0, 0, 3, 231, 0x80, 0, 0, 16, 0, 0, 0x28, 0xaf, 0, 0, 0, 1, // unknown vendor AVP, type from Go
+	})
+
+	// time and address encoding is what the getters read back
+	ts := time.Date(2019, 10, 8, 15, 34, 59, 0, time.UTC)
+	var w struct {
+		Time time.Time `avp:"Event-Timestamp"`
+		IP   net.IP    `avp:"Host-IP-Address"`
+		IP6  net.IP    `avp:"0/257"`
+	}
+	w.Time, w.IP, w.IP6 = ts, net.ParseIP("192.168.0.1"), net.ParseIP("2001:db8::1")
+	dia, err := Marshal(Header{}, w)
+	a.NilError(t, err)
+	ai := NewAvpIndexer(dia)
+	a.Equal(t, ai.GetTime(0, 55), ts)
+	a.DeepEqual(t, dia.AVPs[1].Data, []byte{0, 1, 192, 168, 0, 1})
+	a.Equal(t, ai.GetAllIPAddress(0, 257)[1].String(), "2001:db8::1")
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := MarshalBytes(Header{}, 5)
+	a.ErrorContains(t, err, "need a struct")
+
+	var badType struct {
+		RG string `avp:"Rating-Group"`
+	}
+	_, err = MarshalBytes(Header{}, badType)
+	a.Assert(t, errors.Is(err, ErrAvpEncode))
+	a.ErrorContains(t, err, "field .RG, Rating-Group (0/432)")
+
+	var overflow struct {
+		RG int64 `avp:"Rating-Group"`
+	}
+	overflow.RG = -1
+	_, err = MarshalBytes(Header{}, overflow)
+	a.ErrorContains(t, err, "out of range")
+
+	var noType struct {
+		X int `avp:"99/1"`
+	}
+	_, err = MarshalBytes(Header{}, noType)
+	a.ErrorContains(t, err, "no AVP type for int")
+
+	var wildcard struct {
+		X uint32 `avp:"**/0/432"`
+	}
+	_, err = MarshalBytes(Header{}, wildcard)
+	a.ErrorContains(t, err, "wildcards")
+
+	var notGrouped struct {
+		X struct {
+			Y uint32 `avp:"0/432"`
+		} `avp:"Session-Id"`
+	}
+	_, err = MarshalBytes(Header{}, notGrouped)
+	a.ErrorContains(t, err, "Session-Id (0/263) is not Grouped")
+}
@@ -0,0 +1,288 @@
+package avpindexer
+
+import (
+	"net"
+	"time"
+)
+
+// Retrieval of repeated AVPs: typed slices of all matching values, and positional helpers.  AVPs that can't be read
+// as the requested type are left out of the slices, like GetAll[T] does.
+
+// append values of all matching AVPs that are a D whose data decoded to dst, in message order
+func appendValues[D valueDecoder[V], V any](ai AvpIndexer, path *pathElement, dst []V) []V {
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		if dec, ok := decoderAs[D](pe.avp); ok {
+			dst = append(dst, dec.Get())
+		}
+		return true
+	})
+	return dst
+}
+
+// same as appendValues, into a new slice
+func allValues[D valueDecoder[V], V any](ai AvpIndexer, path *pathElement) []V {
+	return appendValues[D, V](ai, path, nil)
+}
+
+// nth AVP matching path in message order (0 is the first), or nil
+func (ai AvpIndexer) nthAvp(path *pathElement, n int) *AVP {
+	if path == nil || n < 0 {
+		return nil
+	}
+	var avp *AVP
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		if n == 0 {
+			avp = pe.avp
+			return false
+		}
+		n--
+		return true
+	})
+	return avp
+}
+
+// last AVP matching path in message order, or nil
+func (ai AvpIndexer) lastAvp(path *pathElement) *AVP {
+	if path == nil {
+		return nil
+	}
+	var avp *AVP
+	ai.scan(path, true, func(pe *pathElementLeafNode) bool {
+		avp = pe.avp
+		return false
+	})
+	return avp
+}
+
+// number of AVPs matching path
+func (ai AvpIndexer) countAvps(path *pathElement) int {
+	if path == nil {
+		return 0
+	}
+	return ai.visitIntfcp(path, func(*AVP) {})
+}
+
+// number of AVPs matching given id
+func (ai AvpIndexer) Count(vendorId, attrId uint32) int {
+	return ai.countAvps(leafPath(nil, vendorId, attrId))
+}
+
+// number of AVPs matching given id
+func (aip avpIndexerWithPath) Count(vendorId, attrId uint32) int {
+	return aip.countAvps(leafPath(aip.parent, vendorId, attrId))
+}
+
+// number of matching AVPs
+func (q avpQuery) Count() int {
+	return q.compiled().Count(q.ai)
+}
+
+// true if at least one AVP matches given id
+func (ai AvpIndexer) Exists(vendorId, attrId uint32) bool {
+	return ai.nthAvp(leafPath(nil, vendorId, attrId), 0) != nil
+}
+
+// true if at least one AVP matches given id
+func (aip avpIndexerWithPath) Exists(vendorId, attrId uint32) bool {
+	return aip.nthAvp(leafPath(aip.parent, vendorId, attrId), 0) != nil
+}
+
+// true if at least one AVP matches
+func (q avpQuery) Exists() bool {
+	return q.compiled().Exists(q.ai)
+}
+
+// first AVP matching given id in message order, or nil
+func (ai AvpIndexer) First(vendorId, attrId uint32) *AVP {
+	return ai.nthAvp(leafPath(nil, vendorId, attrId), 0)
+}
+
+// first AVP matching given id in message order, or nil
+func (aip avpIndexerWithPath) First(vendorId, attrId uint32) *AVP {
+	return aip.nthAvp(leafPath(aip.parent, vendorId, attrId), 0)
+}
+
+// first matching AVP in message order, or nil
+func (q avpQuery) First() *AVP {
+	return q.compiled().First(q.ai)
+}
+
+// last AVP matching given id in message order, or nil
+func (ai AvpIndexer) Last(vendorId, attrId uint32) *AVP {
+	return ai.lastAvp(leafPath(nil, vendorId, attrId))
+}
+
+// last AVP matching given id in message order, or nil
+func (aip avpIndexerWithPath) Last(vendorId, attrId uint32) *AVP {
+	return aip.lastAvp(leafPath(aip.parent, vendorId, attrId))
+}
+
+// last matching AVP in message order, or nil
+func (q avpQuery) Last() *AVP {
+	return q.compiled().Last(q.ai)
+}
+
+// i'th AVP (0 based) matching given id in message order, or nil if there are not that many
+func (ai AvpIndexer) Nth(vendorId, attrId uint32, i int) *AVP {
+	return ai.nthAvp(leafPath(nil, vendorId, attrId), i)
+}
+
+// i'th AVP (0 based) matching given id in message order, or nil if there are not that many
+func (aip avpIndexerWithPath) Nth(vendorId, attrId uint32, i int) *AVP {
+	return aip.nthAvp(leafPath(aip.parent, vendorId, attrId), i)
+}
+
+// i'th matching AVP (0 based) in message order, or nil if there are not that many
+func (q avpQuery) Nth(i int) *AVP {
+	return q.compiled().Nth(q.ai, i)
+}
+
+// retrieve uint32 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllUint32(vendorId, attrId uint32) []uint32 {
+	return allValues[*DiameterUnsigned32, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve uint32 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllUint32(vendorId, attrId uint32) []uint32 {
+	return allValues[*DiameterUnsigned32, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve uint32 values of all matching AVPs, in message order
+func (q avpQuery) GetAllUint32() []uint32 {
+	return q.compiled().AppendUint32(q.ai, nil)
+}
+
+// retrieve enumerated (uint32) values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllEnumerated(vendorId, attrId uint32) []uint32 {
+	return allValues[*DiameterEnumerated, uint32](ai.at(vendorId, attrId))
+}
+
+// retrieve enumerated (uint32) values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllEnumerated(vendorId, attrId uint32) []uint32 {
+	return allValues[*DiameterEnumerated, uint32](aip.at(vendorId, attrId))
+}
+
+// retrieve enumerated (uint32) values of all matching AVPs, in message order
+func (q avpQuery) GetAllEnumerated() []uint32 {
+	return q.compiled().AppendEnumerated(q.ai, nil)
+}
+
+// retrieve uint64 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllUint64(vendorId, attrId uint32) []uint64 {
+	return allValues[*DiameterUnsigned64, uint64](ai.at(vendorId, attrId))
+}
+
+// retrieve uint64 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllUint64(vendorId, attrId uint32) []uint64 {
+	return allValues[*DiameterUnsigned64, uint64](aip.at(vendorId, attrId))
+}
+
+// retrieve uint64 values of all matching AVPs, in message order
+func (q avpQuery) GetAllUint64() []uint64 {
+	return q.compiled().AppendUint64(q.ai, nil)
+}
+
+// retrieve int32 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllInt32(vendorId, attrId uint32) []int32 {
+	return allValues[*DiameterInteger32, int32](ai.at(vendorId, attrId))
+}
+
+// retrieve int32 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllInt32(vendorId, attrId uint32) []int32 {
+	return allValues[*DiameterInteger32, int32](aip.at(vendorId, attrId))
+}
+
+// retrieve int32 values of all matching AVPs, in message order
+func (q avpQuery) GetAllInt32() []int32 {
+	return q.compiled().AppendInt32(q.ai, nil)
+}
+
+// retrieve int64 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllInt64(vendorId, attrId uint32) []int64 {
+	return allValues[*DiameterInteger64, int64](ai.at(vendorId, attrId))
+}
+
+// retrieve int64 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllInt64(vendorId, attrId uint32) []int64 {
+	return allValues[*DiameterInteger64, int64](aip.at(vendorId, attrId))
+}
+
+// retrieve int64 values of all matching AVPs, in message order
+func (q avpQuery) GetAllInt64() []int64 {
+	return q.compiled().AppendInt64(q.ai, nil)
+}
+
+// retrieve float32 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllFloat32(vendorId, attrId uint32) []float32 {
+	return allValues[*DiameterFloat32, float32](ai.at(vendorId, attrId))
+}
+
+// retrieve float32 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllFloat32(vendorId, attrId uint32) []float32 {
+	return allValues[*DiameterFloat32, float32](aip.at(vendorId, attrId))
+}
+
+// retrieve float32 values of all matching AVPs, in message order
+func (q avpQuery) GetAllFloat32() []float32 {
+	return q.compiled().AppendFloat32(q.ai, nil)
+}
+
+// retrieve float64 values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllFloat64(vendorId, attrId uint32) []float64 {
+	return allValues[*DiameterFloat64, float64](ai.at(vendorId, attrId))
+}
+
+// retrieve float64 values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllFloat64(vendorId, attrId uint32) []float64 {
+	return allValues[*DiameterFloat64, float64](aip.at(vendorId, attrId))
+}
+
+// retrieve float64 values of all matching AVPs, in message order
+func (q avpQuery) GetAllFloat64() []float64 {
+	return q.compiled().AppendFloat64(q.ai, nil)
+}
+
+// retrieve time.Time values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllTime(vendorId, attrId uint32) []time.Time {
+	return allValues[*DiameterTime, time.Time](ai.at(vendorId, attrId))
+}
+
+// retrieve time.Time values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllTime(vendorId, attrId uint32) []time.Time {
+	return allValues[*DiameterTime, time.Time](aip.at(vendorId, attrId))
+}
+
+// retrieve time.Time values of all matching AVPs, in message order
+func (q avpQuery) GetAllTime() []time.Time {
+	return q.compiled().AppendTime(q.ai, nil)
+}
+
+// retrieve string values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllUTF8String(vendorId, attrId uint32) []string {
+	return allValues[*DiameterOctetString, string](ai.at(vendorId, attrId))
+}
+
+// retrieve string values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllUTF8String(vendorId, attrId uint32) []string {
+	return allValues[*DiameterOctetString, string](aip.at(vendorId, attrId))
+}
+
+// retrieve string values of all matching AVPs, in message order
+func (q avpQuery) GetAllUTF8String() []string {
+	return q.compiled().AppendUTF8String(q.ai, nil)
+}
+
+// retrieve net.IP values of all matching AVPs with given id, in message order
+func (ai AvpIndexer) GetAllIPAddress(vendorId, attrId uint32) []net.IP {
+	return allValues[*DiameterIPAddress, net.IP](ai.at(vendorId, attrId))
+}
+
+// retrieve net.IP values of all matching AVPs with given id, in message order
+func (aip avpIndexerWithPath) GetAllIPAddress(vendorId, attrId uint32) []net.IP {
+	return allValues[*DiameterIPAddress, net.IP](aip.at(vendorId, attrId))
+}
+
+// retrieve net.IP values of all matching AVPs, in message order
+func (q avpQuery) GetAllIPAddress() []net.IP {
+	return q.compiled().AppendIPAddress(q.ai, nil)
+}
@@ -0,0 +1,52 @@
+package avpindexer
+
+import (
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestGetAll(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.DeepEqual(t, ai.GetAllUint32(0, 432), []uint32{0, 4001})
+	a.DeepEqual(t, ai.FromGroup(10415, 2040).GetAllUint64(0, 364), []uint64{3208, 26694})
+	a.DeepEqual(t, ai.Query("10415/2040/10415/2045").GetAllUint32(), []uint32{241, 600})
+	a.DeepEqual(t, ai.GetAllInt32(10415, 2037), []int32{1, 1, 1})
+	a.DeepEqual(t, ai.AtRoot().GetAllInt32(10415, 2037), []int32(nil))
+	a.DeepEqual(t, ai.FromGroup(0, 443).GetAllUTF8String(0, 444), []string{"41576568877"})
+	a.DeepEqual(t, ai.GetAllEnumerated(0, 450), []uint32{0})
+	a.Equal(t, len(ai.GetAllIPAddress(10415, 1228)), 3)
+
+	times := ai.FromGroup(10415, 2040).GetAllTime(10415, 2044)
+	a.Equal(t, len(times), 2)
+	a.Assert(t, times[0].Before(times[1]))
+
+	// wrong type is skipped rather than panicking
+	a.Equal(t, len(ai.GetAllUint64(0, 432)), 0)
+	a.Equal(t, len(ai.Query("0/x").GetAllUint32()), 0)
+}
+
+func TestPositional(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.Count(0, 364), 2)
+	a.Equal(t, ai.FromGroup(10415, 874).Count(10415, 2040), 2)
+	a.Equal(t, ai.Count(Wildcard, 2037), 3)
+	a.Equal(t, ai.Query("10415/874/10415/1228").Count(), 1)
+	a.Equal(t, ai.Count(0, 99999), 0)
+
+	a.Assert(t, ai.Exists(0, 263))
+	a.Assert(t, !ai.FromGroup(10415, 874).Exists(0, 263))
+	a.Assert(t, ai.Query("/0/263").Exists())
+
+	a.Equal(t, ai.First(0, 432).DecodedValue, "0")
+	a.Equal(t, ai.Last(0, 432).DecodedValue, "4001")
+	a.Equal(t, ai.Nth(0, 432, 1).DecodedValue, "4001")
+	a.Assert(t, ai.Nth(0, 432, 2) == nil)
+	a.Assert(t, ai.Nth(0, 432, -1) == nil)
+	a.Equal(t, ai.FromGroup(10415, 2040).Last(10415, 2045).DecodedValue, "600")
+	a.Equal(t, ai.Query("**/10415/2037").Last(), ai.FromGroup(10415, 874).First(10415, 2037))
+	a.Equal(t, ai.Query("10415/2040/10415/2045").Nth(0), ai.FromGroup(10415, 2040).First(10415, 2045))
+	a.Assert(t, ai.First(0, 99999) == nil)
+	a.Assert(t, ai.Last(0, 99999) == nil)
+}
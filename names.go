@@ -0,0 +1,241 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name based retrieval, resolving AVP names through a dictionary (the one the message was decoded with unless set
+// with WithDictionary).  Names are resolved when the query is built; a name that is unknown, or defined by more than
+// one vendor, gives an error instead of matching nothing.  That happens on every QueryName and GetXxxByName call; a
+// QueryName query can serve several getters on one indexer, and Compile/CompileWith resolve names used message after
+// message once.
+//
+//	n, err := ai.GetUint32ByName("Accounting-Record-Number")
+//	q := ai.QueryName("Multiple-Services-Credit-Control/Used-Service-Unit/CC-Total-Octets")
+//	p, err := ParseNamePath(d, "/Service-Information/**/3GPP:Node-Id")
+//
+// Name paths follow the slash form of ParsePath with one name per element instead of a vendorId/attrId pair: a
+// leading '/' anchors the path at the message root, '**' allows any number of groups in between and '*' matches any
+// AVP.  A name defined by several vendors is qualified as vendor:name, the vendor given by id or dictionary name.
+
+// Parse textual name path into a Path, resolving names with d.
+func ParseNamePath(d *dictionary.Dictionary, s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Path{}, pathError(s, "empty path")
+	}
+
+	var pe *pathElement
+	for i, tok := range strings.Split(s, "/") {
+		switch {
+		case tok == "" && i == 0:
+			pe = rootPathElement
+		case tok == "**":
+			pe = descend(pe)
+		case tok == "":
+			return Path{}, pathError(s, "empty segment")
+		default:
+			id, err := resolveName(d, tok)
+			if err != nil {
+				return Path{}, fmt.Errorf("invalid avp path %q: %w", s, err)
+			}
+			pe = &pathElement{avpId: id, parent: pe}
+		}
+	}
+
+	if pe == nil || pe.kind != kindAvp {
+		return Path{}, pathError(s, "path must end with an avp name")
+	}
+	return Path{leaf: pe}, nil
+}
+
+// Same as ParseNamePath but panics on error; for paths fixed at compile time.
+func MustParseNamePath(d *dictionary.Dictionary, s string) Path {
+	p, err := ParseNamePath(d, s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func resolveName(d *dictionary.Dictionary, tok string) (avpId, error) {
+	tok = strings.TrimSpace(tok)
+	if tok == "*" {
+		return avpId{vendorId: wildcardValue, attrId: wildcardValue}, nil
+	}
+	var def *dictionary.AVP
+	var err error
+	if i := strings.IndexByte(tok, ':'); i >= 0 {
+		vendor, name := tok[:i], tok[i+1:]
+		vid, perr := strconv.ParseUint(vendor, 10, 32)
+		if perr != nil {
+			v := d.VendorByName(vendor)
+			if v == nil {
+				return avpId{}, fmt.Errorf("unknown vendor %q", vendor)
+			}
+			vid = uint64(v.ID)
+		}
+		def, err = d.AVPByVendorName(uint32(vid), name)
+	} else {
+		def, err = d.AVPByName(tok)
+	}
+	if errors.Is(err, dictionary.ErrAmbiguousName) {
+		return avpId{}, fmt.Errorf("%w; qualify it as vendor:name", err)
+	}
+	if err != nil {
+		return avpId{}, err
+	}
+	return avpId{vendorId: def.VendorID, attrId: def.Code}, nil
+}
+
+// dictionary used for names and enumerated values: the one set by WithDictionary, else the one the message was
+// decoded with, else the bundled default
+func (ai AvpIndexer) dictionary() *dictionary.Dictionary {
+	switch {
+	case ai.dict != nil:
+		return ai.dict
+	case ai.ix != nil && ai.ix.dict != nil:
+		return ai.ix.dict
+	}
+	return dictionary.Default()
+}
+
+// return indexer that resolves AVP names and enumerated values with d instead of the message's dictionary
+func (ai AvpIndexer) WithDictionary(d *dictionary.Dictionary) AvpIndexer {
+	ai.dict = d
+	return ai
+}
+
+// return indexer that resolves AVP names and enumerated values with d instead of the message's dictionary
+func (aip avpIndexerWithPath) WithDictionary(d *dictionary.Dictionary) avpIndexerWithPath {
+	aip.dict = d
+	return aip
+}
+
+// return query for the textual name path (see ParseNamePath); a path that fails to resolve matches nothing, check
+// Err().
+func (ai AvpIndexer) QueryName(path string) avpQuery {
+	p, err := ParseNamePath(ai.dictionary(), path)
+	q := ai.QueryPath(p)
+	q.err = err
+	return q
+}
+
+// return query for the textual name path (see ParseNamePath), relative to this indexer's group unless anchored at
+// the root.
+func (aip avpIndexerWithPath) QueryName(path string) avpQuery {
+	p, err := ParseNamePath(aip.dictionary(), path)
+	q := aip.QueryPath(p)
+	q.err = err
+	return q
+}
+
+// GetXxxByName methods return the value of the first AVP of that type matching the name (or name path), as GetXxxE
+// does; a name that doesn't resolve is the error.  The name is parsed and resolved on each call, in hot loops use a
+// compiled Query (CompileWith) instead.
+
+// first uint32 value matching given name or name path
+func (ai AvpIndexer) GetUint32ByName(name string) (uint32, error) {
+	return ai.QueryName(name).GetUint32E()
+}
+
+// first uint32 value matching given name or name path
+func (aip avpIndexerWithPath) GetUint32ByName(name string) (uint32, error) {
+	return aip.QueryName(name).GetUint32E()
+}
+
+// first enumerated (uint32) value matching given name or name path
+func (ai AvpIndexer) GetEnumeratedByName(name string) (uint32, error) {
+	return ai.QueryName(name).GetEnumeratedE()
+}
+
+// first enumerated (uint32) value matching given name or name path
+func (aip avpIndexerWithPath) GetEnumeratedByName(name string) (uint32, error) {
+	return aip.QueryName(name).GetEnumeratedE()
+}
+
+// first uint64 value matching given name or name path
+func (ai AvpIndexer) GetUint64ByName(name string) (uint64, error) {
+	return ai.QueryName(name).GetUint64E()
+}
+
+// first uint64 value matching given name or name path
+func (aip avpIndexerWithPath) GetUint64ByName(name string) (uint64, error) {
+	return aip.QueryName(name).GetUint64E()
+}
+
+// first int32 value matching given name or name path
+func (ai AvpIndexer) GetInt32ByName(name string) (int32, error) {
+	return ai.QueryName(name).GetInt32E()
+}
+
+// first int32 value matching given name or name path
+func (aip avpIndexerWithPath) GetInt32ByName(name string) (int32, error) {
+	return aip.QueryName(name).GetInt32E()
+}
+
+// first int64 value matching given name or name path
+func (ai AvpIndexer) GetInt64ByName(name string) (int64, error) {
+	return ai.QueryName(name).GetInt64E()
+}
+
+// first int64 value matching given name or name path
+func (aip avpIndexerWithPath) GetInt64ByName(name string) (int64, error) {
+	return aip.QueryName(name).GetInt64E()
+}
+
+// first float32 value matching given name or name path
+func (ai AvpIndexer) GetFloat32ByName(name string) (float32, error) {
+	return ai.QueryName(name).GetFloat32E()
+}
+
+// first float32 value matching given name or name path
+func (aip avpIndexerWithPath) GetFloat32ByName(name string) (float32, error) {
+	return aip.QueryName(name).GetFloat32E()
+}
+
+// first float64 value matching given name or name path
+func (ai AvpIndexer) GetFloat64ByName(name string) (float64, error) {
+	return ai.QueryName(name).GetFloat64E()
+}
+
+// first float64 value matching given name or name path
+func (aip avpIndexerWithPath) GetFloat64ByName(name string) (float64, error) {
+	return aip.QueryName(name).GetFloat64E()
+}
+
+// first time.Time value matching given name or name path
+func (ai AvpIndexer) GetTimeByName(name string) (time.Time, error) {
+	return ai.QueryName(name).GetTimeE()
+}
+
+// first time.Time value matching given name or name path
+func (aip avpIndexerWithPath) GetTimeByName(name string) (time.Time, error) {
+	return aip.QueryName(name).GetTimeE()
+}
+
+// first string value matching given name or name path
+func (ai AvpIndexer) GetUTF8StringByName(name string) (string, error) {
+	return ai.QueryName(name).GetUTF8StringE()
+}
+
+// first string value matching given name or name path
+func (aip avpIndexerWithPath) GetUTF8StringByName(name string) (string, error) {
+	return aip.QueryName(name).GetUTF8StringE()
+}
+
+// first net.IP value matching given name or name path
+func (ai AvpIndexer) GetIPAddressByName(name string) (net.IP, error) {
+	return ai.QueryName(name).GetIPAddressE()
+}
+
+// first net.IP value matching given name or name path
+func (aip avpIndexerWithPath) GetIPAddressByName(name string) (net.IP, error) {
+	return aip.QueryName(name).GetIPAddressE()
+}
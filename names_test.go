@@ -0,0 +1,62 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestGetByName(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	n, err := ai.GetUint32ByName("Accounting-Record-Number")
+	a.NilError(t, err)
+	a.Equal(t, n, ai.GetUint32(0, 485))
+
+	cid, err := ai.FromGroup(10415, 873).GetUint32ByName("PS-Information/3GPP-Charging-Id")
+	a.NilError(t, err)
+	a.Equal(t, cid, uint32(0x5e9ed913))
+
+	node, err := ai.GetUTF8StringByName("3GPP:Node-Id")
+	a.NilError(t, err)
+	a.Equal(t, node, ai.GetUTF8String(10415, 2064))
+
+	q := ai.QueryName("/Service-Information/PS-Information/Service-Data-Container/Rating-Group")
+	a.NilError(t, q.Err())
+	a.DeepEqual(t, q.GetAllUint32(), []uint32{0, 4001})
+	a.Equal(t, ai.QueryName("Service-Information/**/Time-Usage").Count(), 2)
+	a.Equal(t, ai.FromGroup(10415, 874).QueryName("Service-Data-Container/*").Count(), 26)
+
+	// names resolved, but no such AVP in the message
+	_, err = ai.GetUint64ByName("Multiple-Services-Credit-Control/Used-Service-Unit/CC-Total-Octets")
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+	a.ErrorContains(t, err, "0/456/0/446/0/421")
+}
+
+func TestNameErrors(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	_, err := ai.GetUint32ByName("Service-Information/No-Such-AVP")
+	a.Assert(t, errors.Is(err, dictionary.ErrUnknownAVP))
+	a.ErrorContains(t, err, `"No-Such-AVP"`)
+
+	_, err = ai.GetUint32ByName("Acme:Rating-Group")
+	a.ErrorContains(t, err, `unknown vendor "Acme"`)
+
+	dict := dictionary.Default().Clone()
+	dict.AddAVP(&dictionary.AVP{Name: "Rating-Group", Code: 7, VendorID: 9, Type: dictionary.Unsigned32})
+	ai = ai.WithDictionary(dict)
+	_, err = ai.GetUint32ByName("Rating-Group")
+	a.Assert(t, errors.Is(err, dictionary.ErrAmbiguousName))
+	a.ErrorContains(t, err, "vendor:name")
+
+	rg, err := ai.FromGroup(10415, 874).GetUint32ByName("10415:Service-Data-Container/0:Rating-Group")
+	a.NilError(t, err)
+	a.Equal(t, rg, uint32(0))
+
+	for _, s := range []string{"", "/", "Service-Information/", "Service-Information//Rating-Group", "**"} {
+		_, err := ParseNamePath(dict, s)
+		a.Assert(t, err != nil, s)
+	}
+}
@@ -0,0 +1,271 @@
+package avpindexer
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Textual AVP paths, so that extraction fields can be defined in config files instead of code.
+// Two forms are accepted:
+//
+//	10415/873/10415/874/10415/1228   vendorId/attrId pairs, from the outermost group down to the AVP
+//	10415/1228.10415/874.10415/873   dotted form as rendered by pathElement.skey2(), AVP first
+//
+// '*' matches any vendor or attribute id (see Wildcard).  Each element must be the direct child of the one before
+// it, '**' in place of an element allows any number of groups in between.  A path matches at any depth unless it
+// is anchored at the message root, written as a leading '/' (or a trailing '.' in dotted form):
+//
+//	/10415/873/**/0/432              any Rating-Group within a top level Service-Information
+//	0/432.**.10415/873.              same, dotted
+
+// Path is a parsed AVP path, usable by Query on any indexer.  A Path is never modified once parsed.
+type Path struct {
+	leaf *pathElement
+}
+
+// Parse textual path into a Path.
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Path{}, pathError(s, "empty path")
+	}
+
+	var pe *pathElement
+	if strings.Contains(s, ".") {
+		segs := strings.Split(s, ".")
+		for i := len(segs) - 1; i >= 0; i-- {
+			switch segs[i] {
+			case "":
+				if i != len(segs)-1 || i == 0 {
+					return Path{}, pathError(s, "empty segment")
+				}
+				pe = rootPathElement
+			case "**":
+				pe = descend(pe)
+			default:
+				va := strings.Split(segs[i], "/")
+				if len(va) != 2 {
+					return Path{}, pathError(s, fmt.Sprintf("segment %q is not vendorId/attrId", segs[i]))
+				}
+				id, err := parseAvpId(va[0], va[1])
+				if err != nil {
+					return Path{}, pathError(s, err.Error())
+				}
+				pe = &pathElement{avpId: id, parent: pe}
+			}
+		}
+	} else {
+		toks := strings.Split(s, "/")
+		for i := 0; i < len(toks); i++ {
+			switch {
+			case toks[i] == "" && i == 0:
+				pe = rootPathElement
+			case toks[i] == "**":
+				pe = descend(pe)
+			case i+1 < len(toks):
+				id, err := parseAvpId(toks[i], toks[i+1])
+				if err != nil {
+					return Path{}, pathError(s, err.Error())
+				}
+				pe = &pathElement{avpId: id, parent: pe}
+				i++
+			default:
+				return Path{}, pathError(s, "expected vendorId/attrId pairs")
+			}
+		}
+	}
+
+	if pe == nil || pe.kind != kindAvp {
+		return Path{}, pathError(s, "path must end with vendorId/attrId")
+	}
+	return Path{leaf: pe}, nil
+}
+
+// Same as ParsePath but panics on error; for paths fixed at compile time.
+func MustParsePath(s string) Path {
+	p, err := ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func parseAvpId(vs, as string) (avpId, error) {
+	v, err := parseIdValue(vs)
+	if err != nil {
+		return avpId{}, err
+	}
+	a, err := parseIdValue(as)
+	if err != nil {
+		return avpId{}, err
+	}
+	return avpId{vendorId: v, attrId: a}, nil
+}
+
+func parseIdValue(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	if s == "*" {
+		return wildcardValue, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad id %q", s)
+	}
+	return uint32(n), nil
+}
+
+func pathError(s, reason string) error {
+	return fmt.Errorf("invalid avp path %q: %s", s, reason)
+}
+
+// Format path in the slash separated form accepted by ParsePath.
+func (p Path) String() string {
+	var segs []string
+	for pe := p.leaf; pe != nil; pe = pe.parent {
+		switch pe.kind {
+		case kindAvp:
+			segs = append(segs, pe.skey())
+		case kindDescend:
+			segs = append(segs, "**")
+		case kindRoot:
+			segs = append(segs, "")
+		case kindNode:
+			// a group instance is shown as the anchored path it was found at
+			for n := pe.node; n != nil; n = n.parent {
+				segs = append(segs, n.skey())
+			}
+			segs = append(segs, "")
+		}
+	}
+	for i, j := 0, len(segs)-1; i < j; i, j = i+1, j-1 {
+		segs[i], segs[j] = segs[j], segs[i]
+	}
+	return strings.Join(segs, "/")
+}
+
+// copy of this path with its outermost element placed under parent; p itself is left untouched.  Paths anchored at
+// the message root are returned as is.
+func (p *pathElement) under(parent *pathElement) *pathElement {
+	if p == nil {
+		return parent
+	}
+	if p.kind == kindRoot {
+		return p
+	}
+	c := *p
+	c.parent = p.parent.under(parent)
+	return &c
+}
+
+// a compiled Query bound to the indexer it runs on, see Indexer.Query; its getters are those of the Query
+type avpQuery struct {
+	ai   AvpIndexer
+	path *pathElement // already placed under the indexer's group
+	err  error
+}
+
+// return query for the textual path (see ParsePath); a path that fails to parse matches nothing, check Err().
+func (ai AvpIndexer) Query(path string) avpQuery {
+	p, err := ParsePath(path)
+	q := ai.QueryPath(p)
+	q.err = err
+	return q
+}
+
+// return query for the textual path (see ParsePath), relative to this indexer's group unless anchored at the root.
+func (aip avpIndexerWithPath) Query(path string) avpQuery {
+	p, err := ParsePath(path)
+	q := aip.QueryPath(p)
+	q.err = err
+	return q
+}
+
+// return query for the parsed path.
+func (ai AvpIndexer) QueryPath(p Path) avpQuery {
+	return avpQuery{ai: ai, path: p.leaf}
+}
+
+// return query for the parsed path, relative to this indexer's group unless anchored at the root.
+func (aip avpIndexerWithPath) QueryPath(p Path) avpQuery {
+	if p.leaf == nil {
+		return avpQuery{ai: aip.AvpIndexer}
+	}
+	return avpQuery{ai: aip.AvpIndexer, path: p.leaf.under(aip.parent)}
+}
+
+// error from parsing the query path, if any
+func (q avpQuery) Err() error {
+	return q.err
+}
+
+// the compiled query
+func (q avpQuery) compiled() Query {
+	return Query{path: q.path}
+}
+
+// retrieve first matching uint32 value, or the default/zero value for that type
+func (q avpQuery) GetUint32() uint32 {
+	return q.compiled().GetUint32(q.ai)
+}
+
+// retrieve first matching enumerated (uint32) value, or the default/zero value for that type
+func (q avpQuery) GetEnumerated() uint32 {
+	return q.compiled().GetEnumerated(q.ai)
+}
+
+// retrieve first matching uint64 value, or the default/zero value for that type
+func (q avpQuery) GetUint64() uint64 {
+	return q.compiled().GetUint64(q.ai)
+}
+
+// retrieve first matching int32 value, or the default/zero value for that type
+func (q avpQuery) GetInt32() int32 {
+	return q.compiled().GetInt32(q.ai)
+}
+
+// retrieve first matching int64 value, or the default/zero value for that type
+func (q avpQuery) GetInt64() int64 {
+	return q.compiled().GetInt64(q.ai)
+}
+
+// retrieve first matching float32 value, or the default/zero value for that type
+func (q avpQuery) GetFloat32() float32 {
+	return q.compiled().GetFloat32(q.ai)
+}
+
+// retrieve first matching float64 value, or the default/zero value for that type
+func (q avpQuery) GetFloat64() float64 {
+	return q.compiled().GetFloat64(q.ai)
+}
+
+// retrieve first matching time.Time value, or the default/zero value for that type
+func (q avpQuery) GetTime() time.Time {
+	return q.compiled().GetTime(q.ai)
+}
+
+// retrieve first matching string value, or the default/zero value for that type
+func (q avpQuery) GetUTF8String() string {
+	return q.compiled().GetUTF8String(q.ai)
+}
+
+// retrieve first matching net.IP value, or the default/zero value for that type
+func (q avpQuery) GetIPAddress() net.IP {
+	return q.compiled().GetIPAddress(q.ai)
+}
+
+// invoke f for each matching AVP found.  returns number of times f was invoked.
+func (q avpQuery) VisitAvp(f func(avp *AVP)) int {
+	return q.compiled().VisitAvp(q.ai, f)
+}
+
+// add up uint64 values of all matching AVPs; AVPs of other types are skipped, see Aggregates for those
+func (q avpQuery) AccumulateUint64() uint64 {
+	var sum uint64
+	for _, v := range q.GetAllUint64() {
+		sum += v
+	}
+	return sum
+}
@@ -0,0 +1,88 @@
+package avpindexer
+
+import (
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	p, err := ParsePath("10415/873/10415/874/10415/1228")
+	a.NilError(t, err)
+	a.Equal(t, p.String(), "10415/873/10415/874/10415/1228")
+	a.Equal(t, p.leaf.skey2(), "10415/1228.10415/874.10415/873")
+
+	p2, err := ParsePath(p.leaf.skey2())
+	a.NilError(t, err)
+	a.Equal(t, p2.String(), p.String())
+
+	p, err = ParsePath("*/2040/0/*")
+	a.NilError(t, err)
+	a.Equal(t, p.String(), "*/2040/0/*")
+	a.Equal(t, p.leaf.vendorId, uint32(0))
+	a.Equal(t, p.leaf.attrId, Wildcard)
+
+	for _, s := range []string{"", "10415", "10415/873/0", "x/1", "1/-1", "0/4294967296", "0/444.0", "0/1/2.0/3",
+		"/", "10415//873", "10415/873/**", ".0/444", "0/444..0/443", "**.0/444", "**"} {
+		_, err := ParsePath(s)
+		a.Assert(t, err != nil, s)
+	}
+}
+
+func TestParseAnchoredPath(t *testing.T) {
+	for _, c := range []struct{ in, out, dotted string }{
+		{"/10415/873/**/0/432", "/10415/873/**/0/432", "0/432.**.10415/873."},
+		{"0/432.**.10415/873.", "/10415/873/**/0/432", "0/432.**.10415/873."},
+		{"/0/485", "/0/485", "0/485."},
+		{"**/0/432", "**/0/432", "0/432.**"},
+		{"/**/**/0/432", "/**/0/432", "0/432.**."},
+		{"10415/873/**/**/0/432", "10415/873/**/0/432", "0/432.**.10415/873"},
+	} {
+		p, err := ParsePath(c.in)
+		a.NilError(t, err, c.in)
+		a.Equal(t, p.String(), c.out)
+		a.Equal(t, p.leaf.skey2(), c.dotted)
+	}
+}
+
+func TestAnchoredQuery(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.Query("/0/485").GetUint32(), uint32(1))
+	a.Equal(t, ai.Query("/10415/2045").GetUint32(), uint32(0))
+	a.Equal(t, ai.Query("10415/2045").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("/10415/873/**/10415/2045").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("/10415/873/10415/2040/10415/2045").GetUint32(), uint32(0))
+	a.Equal(t, ai.Query("/10415/873/10415/874/10415/2040/10415/2045").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("10415/873/**/0/364").AccumulateUint64(), uint64(3208+26694))
+	a.Equal(t, ai.Query("10415/873/0/364").AccumulateUint64(), uint64(0))
+
+	// anchored query ignores the group it is run from, relative '**' query does not
+	a.Equal(t, ai.FromGroup(10415, 876).Query("/0/485").GetUint32(), uint32(1))
+	a.Equal(t, ai.FromGroup(10415, 876).Query("**/0/485").GetUint32(), uint32(0))
+	a.Equal(t, ai.FromGroup(10415, 873).Query("**/0/364").AccumulateUint64(), uint64(3208+26694))
+}
+
+func TestQuery(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.Query("10415/2040/10415/2045").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("10415/874/10415/2040/10415/2045").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("10415/2045.10415/2040").GetUint32(), uint32(241))
+	a.Equal(t, ai.Query("0/485").GetUint32(), uint32(1))
+	a.Equal(t, ai.Query("*/2040/0/364").AccumulateUint64(), uint64(3208+26694))
+	a.DeepEqual(t, ai.Query("10415/874/10415/1228").GetIPAddress(), ai.FromGroup(10415, 874).GetIPAddress(10415, 1228))
+
+	a.Equal(t, ai.FromGroup(10415, 874).Query("10415/2040/0/364").GetUint64(), uint64(3208))
+	a.Equal(t, ai.FromGroup(10415, 873).Query("10415/2040/0/364").GetUint64(), uint64(0))
+
+	q := ai.Query("10415/bogus")
+	a.Assert(t, q.Err() != nil)
+	a.Equal(t, q.GetUint32(), uint32(0))
+	a.Equal(t, q.VisitAvp(nil), 0)
+
+	// relative query must not modify the parsed path
+	p := MustParsePath("0/364")
+	a.Equal(t, ai.FromGroup(10415, 2040).QueryPath(p).AccumulateUint64(), uint64(3208+26694))
+	a.Equal(t, p.String(), "0/364")
+	a.Assert(t, p.leaf.parent == nil)
+}
@@ -0,0 +1,309 @@
+package avpindexer
+
+import (
+	"math/bits"
+	"net"
+	"time"
+)
+
+// Projection plans: a fixed set of compiled queries evaluated together in one pass over a message, without building
+// an index.  Subtrees no query can reach are skipped, so for queries anchored at the root ('/...') only the groups
+// on their paths are visited:
+//
+//	plan := NewPlan(MustCompile("/Session-Id"), MustCompile("/Multiple-Services-Credit-Control/Rating-Group"))
+//	var r *Projection
+//	for dia := range msgs {
+//		r = plan.Run(dia, r)               r's storage is reused
+//		sid, rg := r.GetUTF8String(0), r.GetUint32(1)
+//		rgs := r.All(1)
+//	}
+//
+// Queries are referred to by their position in NewPlan's arguments.  A Plan is immutable and safe for concurrent
+// use, each goroutine with its own Projection.  A query that can match at any depth (not anchored, or '**') makes
+// the plan visit every group that may hold a match, which for an unanchored query is all of them.
+
+// Plan is a set of queries to run together, see NewPlan.
+type Plan struct {
+	queries []planQuery
+}
+
+// a query's path from the root down as an automaton: state bit i set means steps[i] is next to match
+type planQuery struct {
+	steps []planStep
+	start uint64 // states at the message root
+}
+
+type planStep struct {
+	id      avpId
+	descend bool // '**': any number of groups, id unused
+}
+
+// most steps a plan query can have; longer paths match nothing
+const maxPlanSteps = 64
+
+// Plan for running queries together; results are per query, in the order given.
+func NewPlan(queries ...Query) *Plan {
+	p := &Plan{queries: make([]planQuery, len(queries))}
+	for i, q := range queries {
+		p.queries[i] = newPlanQuery(q.path)
+	}
+	return p
+}
+
+func newPlanQuery(path *pathElement) planQuery {
+	if path == nil {
+		return planQuery{}
+	}
+	var steps []planStep
+	anchored := false
+	for pe := path; pe != nil; pe = pe.parent {
+		switch pe.kind {
+		case kindAvp:
+			steps = append(steps, planStep{id: pe.avpId})
+		case kindDescend:
+			steps = append(steps, planStep{descend: true})
+		case kindRoot:
+			anchored = true
+		case kindNode:
+			// one group instance of some indexed message; never in a plan's messages
+			return planQuery{}
+		}
+	}
+	if !anchored {
+		steps = append(steps, planStep{descend: true})
+	}
+	if len(steps) > maxPlanSteps {
+		return planQuery{}
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	q := planQuery{steps: steps}
+	q.start = q.closure(1)
+	return q
+}
+
+// states s plus those reachable by matching '**' steps to no groups
+func (q *planQuery) closure(s uint64) uint64 {
+	for i, st := range q.steps {
+		if st.descend && s&(1<<i) != 0 {
+			s |= 1 << (i + 1)
+		}
+	}
+	return s
+}
+
+// states after an AVP with id, given the states at its parent; matched if the AVP completes the path
+func (q *planQuery) next(s uint64, id avpId) (next uint64, matched bool) {
+	for s != 0 {
+		i := bits.TrailingZeros64(s)
+		s &= s - 1
+		st := q.steps[i]
+		switch {
+		case st.descend:
+			next |= 1 << i
+		case st.id.matchesId(id):
+			if i == len(q.steps)-1 {
+				matched = true
+			} else {
+				next |= 1 << (i + 1)
+			}
+		}
+	}
+	return q.closure(next), matched
+}
+
+// Projection holds the results of a Plan run on one message.  The AVPs belong to that message.
+type Projection struct {
+	hits    [][]*AVP // per query, matching AVPs in message order
+	states  []uint64 // per depth, per query
+	visited int      // AVPs looked at
+}
+
+// Run the plan's queries on d, filling r (a new Projection if nil) and returning it.  r's storage is reused, so
+// results from earlier runs into r are gone.
+func (p *Plan) Run(d *Diameter, r *Projection) *Projection {
+	if r == nil {
+		r = &Projection{}
+	}
+	n := len(p.queries)
+	if cap(r.hits) < n {
+		r.hits = make([][]*AVP, n)
+	}
+	r.hits = r.hits[:n]
+	for i := range r.hits {
+		r.hits[i] = r.hits[i][:0]
+	}
+	if len(r.states) < n {
+		r.states = make([]uint64, 4*n)
+	}
+	for i := range p.queries {
+		r.states[i] = p.queries[i].start
+	}
+	r.visited = 0
+	p.visit(r, d.AVPs, 0)
+	return r
+}
+
+// match avps, whose parent's states are at depth, and descend into groups some query can still match in
+func (p *Plan) visit(r *Projection, avps []*AVP, depth int) {
+	n := len(p.queries)
+	if len(r.states) < (depth+2)*n {
+		r.states = append(r.states, make([]uint64, (depth+2)*n-len(r.states))...)
+	}
+	for _, avp := range avps {
+		r.visited++
+		id := avpId{vendorId: avp.VendorCode, attrId: avp.AttributeCode}
+		at, below := r.states[depth*n:(depth+1)*n], r.states[(depth+1)*n:(depth+2)*n]
+		var live uint64
+		for i := range p.queries {
+			s, matched := p.queries[i].next(at[i], id)
+			if matched {
+				r.hits[i] = append(r.hits[i], avp)
+			}
+			below[i] = s
+			live |= s
+		}
+		if live != 0 && len(avp.Grouped) > 0 {
+			p.visit(r, avp.Grouped, depth+1)
+		}
+	}
+}
+
+// AVPs matching query i, in message order
+func (r *Projection) All(i int) []*AVP {
+	return r.hits[i]
+}
+
+// number of AVPs matching query i
+func (r *Projection) Count(i int) int {
+	return len(r.hits[i])
+}
+
+// first AVP matching query i in message order, or nil
+func (r *Projection) First(i int) *AVP {
+	if len(r.hits[i]) == 0 {
+		return nil
+	}
+	return r.hits[i][0]
+}
+
+// value of the first AVP in avps that is a D, as the indexers' typed getters pick it
+func lookupIn[D valueDecoder[V], V any](avps []*AVP) (V, bool) {
+	for _, avp := range avps {
+		if isType[D](avp) {
+			return lookupAvp[D, V](avp)
+		}
+	}
+	return lookupAvp[D, V](nil)
+}
+
+// retrieve first uint32 value matching query i, or the default/zero value for that type
+func (r *Projection) GetUint32(i int) uint32 {
+	v, _ := r.LookupUint32(i)
+	return v
+}
+
+// retrieve first uint32 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupUint32(i int) (uint32, bool) {
+	return lookupIn[*DiameterUnsigned32, uint32](r.hits[i])
+}
+
+// retrieve first enumerated (uint32) value matching query i, or the default/zero value for that type
+func (r *Projection) GetEnumerated(i int) uint32 {
+	v, _ := r.LookupEnumerated(i)
+	return v
+}
+
+// retrieve first enumerated (uint32) value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupEnumerated(i int) (uint32, bool) {
+	return lookupIn[*DiameterEnumerated, uint32](r.hits[i])
+}
+
+// retrieve first uint64 value matching query i, or the default/zero value for that type
+func (r *Projection) GetUint64(i int) uint64 {
+	v, _ := r.LookupUint64(i)
+	return v
+}
+
+// retrieve first uint64 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupUint64(i int) (uint64, bool) {
+	return lookupIn[*DiameterUnsigned64, uint64](r.hits[i])
+}
+
+// retrieve first int32 value matching query i, or the default/zero value for that type
+func (r *Projection) GetInt32(i int) int32 {
+	v, _ := r.LookupInt32(i)
+	return v
+}
+
+// retrieve first int32 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupInt32(i int) (int32, bool) {
+	return lookupIn[*DiameterInteger32, int32](r.hits[i])
+}
+
+// retrieve first int64 value matching query i, or the default/zero value for that type
+func (r *Projection) GetInt64(i int) int64 {
+	v, _ := r.LookupInt64(i)
+	return v
+}
+
+// retrieve first int64 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupInt64(i int) (int64, bool) {
+	return lookupIn[*DiameterInteger64, int64](r.hits[i])
+}
+
+// retrieve first float32 value matching query i, or the default/zero value for that type
+func (r *Projection) GetFloat32(i int) float32 {
+	v, _ := r.LookupFloat32(i)
+	return v
+}
+
+// retrieve first float32 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupFloat32(i int) (float32, bool) {
+	return lookupIn[*DiameterFloat32, float32](r.hits[i])
+}
+
+// retrieve first float64 value matching query i, or the default/zero value for that type
+func (r *Projection) GetFloat64(i int) float64 {
+	v, _ := r.LookupFloat64(i)
+	return v
+}
+
+// retrieve first float64 value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupFloat64(i int) (float64, bool) {
+	return lookupIn[*DiameterFloat64, float64](r.hits[i])
+}
+
+// retrieve first time.Time value matching query i, or the default/zero value for that type
+func (r *Projection) GetTime(i int) time.Time {
+	v, _ := r.LookupTime(i)
+	return v
+}
+
+// retrieve first time.Time value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupTime(i int) (time.Time, bool) {
+	return lookupIn[*DiameterTime, time.Time](r.hits[i])
+}
+
+// retrieve first string value matching query i, or the default/zero value for that type
+func (r *Projection) GetUTF8String(i int) string {
+	v, _ := r.LookupUTF8String(i)
+	return v
+}
+
+// retrieve first string value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupUTF8String(i int) (string, bool) {
+	return lookupIn[*DiameterOctetString, string](r.hits[i])
+}
+
+// retrieve first net.IP value matching query i, or the default/zero value for that type
+func (r *Projection) GetIPAddress(i int) net.IP {
+	v, _ := r.LookupIPAddress(i)
+	return v
+}
+
+// retrieve first net.IP value matching query i; false if there is none of that type or it didn't decode
+func (r *Projection) LookupIPAddress(i int) (net.IP, bool) {
+	return lookupIn[*DiameterIPAddress, net.IP](r.hits[i])
+}
@@ -0,0 +1,127 @@
+package avpindexer
+
+import (
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestPlanMatchesQueries(t *testing.T) {
+	paths := append([]string{
+		"/Session-Id",
+		"Service-Information/PS-Information/Service-Data-Container/Time-Usage",
+		"/Service-Information/**/Rating-Group",
+		"**/Service-Information/**/*/**/Rating-Group",
+		"*/*",
+		"/*/*",
+		"/0/456/**/0/421",
+	}, trieTestPaths...)
+	var qs []Query
+	for _, s := range paths {
+		qs = append(qs, MustCompile(s))
+	}
+	plan := NewPlan(qs...)
+
+	var r *Projection
+	for name, dia := range map[string]*Diameter{"small": d, "large": largeMessage(t, 60)} {
+		ai := NewAvpIndexer(dia)
+		r = plan.Run(dia, r)
+		for i, q := range qs {
+			var want []*AVP
+			q.VisitAvp(ai, func(avp *AVP) { want = append(want, avp) })
+			a.Equal(t, r.Count(i), len(want), "%s %s", name, paths[i])
+			for j := range want {
+				a.Equal(t, r.All(i)[j], want[j], "%s %s", name, paths[i])
+			}
+			a.Equal(t, r.First(i), q.First(ai))
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	plan := NewPlan(
+		MustCompile("/Session-Id"),
+		MustCompile("/Service-Information/PS-Information/Service-Data-Container/Time-Usage"),
+		MustCompile("/Service-Information/PS-Information/3GPP-Charging-Id"),
+		MustCompile("/Accounting-Record-Type"),
+		MustCompile("/1/2/0/263"),
+		Query{},
+	)
+	r := plan.Run(d, nil)
+	ai := NewAvpIndexer(d)
+	a.Equal(t, r.GetUTF8String(0), ai.GetUTF8String(0, 263))
+	a.Equal(t, r.GetUint32(1), uint32(241))
+	a.Equal(t, r.Count(1), 2)
+	a.Equal(t, r.GetUint32(2), uint32(0x5e9ed913))
+	a.Equal(t, r.GetEnumerated(3), uint32(4))
+	a.Equal(t, r.Count(4), 0)
+	a.Equal(t, r.Count(5), 0)
+	_, ok := r.LookupUint32(0)
+	a.Assert(t, !ok)
+
+	// only the groups on the anchored paths are looked into: the 14 top level AVPs, 3 in Service-Information, 23
+	// in PS-Information and 2x13 in the containers
+	a.Equal(t, r.visited, 14+3+23+2*13)
+
+	// reused for another message
+	other, err := NewMessage(271, 3, true).AVP(0, 263, "other").Diameter()
+	a.NilError(t, err)
+	r2 := plan.Run(other, r)
+	a.Assert(t, r2 == r)
+	a.Equal(t, r.GetUTF8String(0), "other")
+	a.Equal(t, r.Count(1), 0)
+	a.Equal(t, r.visited, 1)
+}
+
+func TestPlanAllocs(t *testing.T) {
+	dia := largeMessage(t, 60)
+	plan := NewPlan(MustCompile("/Session-Id"), MustCompile("/Multiple-Services-Credit-Control/Rating-Group"),
+		MustCompile("**/CC-Input-Octets"))
+	r := plan.Run(dia, nil)
+	allocs := testing.AllocsPerRun(100, func() {
+		r = plan.Run(dia, r)
+		r.GetUTF8String(0)
+		r.GetUint32(1)
+	})
+	a.Equal(t, allocs, float64(0))
+	a.Equal(t, r.Count(1), 60)
+	a.Equal(t, r.Count(2), 60)
+}
+
+func BenchmarkPlan(b *testing.B) {
+	msgs := map[string]*Diameter{"small": d, "large": largeMessage(b, 60)}
+	anchored := []Query{
+		MustCompile("/Session-Id"),
+		MustCompile("/Origin-Host"),
+		MustCompile("/Accounting-Record-Type"),
+		MustCompile("/Multiple-Services-Credit-Control/Rating-Group"),
+		MustCompile("/Multiple-Services-Credit-Control/Used-Service-Unit/CC-Input-Octets"),
+		MustCompile("/Service-Information/PS-Information/3GPP-Charging-Id"),
+		MustCompile("/Service-Information/PS-Information/SGSN-Address"),
+	}
+	unanchored := []Query{MustCompile("Session-Id"), MustCompile("Rating-Group"), MustCompile("**/SGSN-Address")}
+	for _, size := range []string{"small", "large"} {
+		dia := msgs[size]
+		for name, qs := range map[string][]Query{"anchored": anchored, "unanchored": unanchored} {
+			plan := NewPlan(qs...)
+			b.Run(size+"/"+name+"/plan", func(b *testing.B) {
+				var r *Projection
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					r = plan.Run(dia, r)
+					for j := range qs {
+						r.First(j)
+					}
+				}
+			})
+			b.Run(size+"/"+name+"/indexer", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					ai := NewAvpIndexer(dia)
+					for _, q := range qs {
+						q.First(ai)
+					}
+				}
+			})
+		}
+	}
+}
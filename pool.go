@@ -0,0 +1,84 @@
+package avpindexer
+
+import (
+	"sync"
+)
+
+// Reusing indexers.  NewAvpIndexer allocates the index storage for every message; an indexer that's Reset instead
+// keeps that storage, so once it has seen a message of similar size, indexing and lookups don't allocate at all.
+//
+//	ai := avpindexer.AcquireAvpIndexer(dia)
+//	defer avpindexer.ReleaseAvpIndexer(ai)
+//	rg := ratingGroup.GetUint32(ai)
+//
+// or, with one indexer per goroutine:
+//
+//	var ai avpindexer.AvpIndexer
+//	for dia := range msgs {
+//		ai.Reset(dia)
+//		...
+//	}
+
+// Re-index ai for d (nil for no message), reusing the storage of ai's previous index.  Names and enumerated values
+// are looked up in the dictionary d was decoded with, as for NewAvpIndexer, unless one was set with WithDictionary:
+// that is kept.  The previous index is overwritten, so every indexer that shares it -- copies of ai, and
+// those made from it by FromGroup, EachGroup, Groups and so on -- must be done with before Reset is called, and none
+// of them may be used concurrently with it.  Used afterwards, they match nothing rather than d's AVPs.
+func (ai *AvpIndexer) Reset(d *Diameter) {
+	var avps []*AVP
+	if d != nil {
+		avps = d.AVPs
+	}
+	if ai.ix == nil {
+		ai.ix = newAvpIndex(avps)
+	} else {
+		if ai.ix.lazy != nil && d != &ai.ix.lazy.msg {
+			ai.ix.lazy.clear()
+		}
+		ai.ix.reset(avps)
+	}
+	ai.ix.dict = nil
+	if d != nil {
+		ai.ix.dict = d.dict
+	}
+	ai.gen = ai.ix.gen
+}
+
+// indexers whose storage is larger than this aren't pooled, so one huge message doesn't pin its storage forever
+const maxPooledAvps = 1 << 12
+
+var indexerPool = sync.Pool{
+	New: func() interface{} { return new(AvpIndexer) },
+}
+
+// An indexer for d from a package wide pool.  Hand it back with ReleaseAvpIndexer once it and every indexer made
+// from it are no longer used.  For raw messages, acquire one for nil and ResetBytes it.
+func AcquireAvpIndexer(d *Diameter) *AvpIndexer {
+	ai := indexerPool.Get().(*AvpIndexer)
+	ai.Reset(d)
+	return ai
+}
+
+// Return an indexer from AcquireAvpIndexer (or any *AvpIndexer) to the pool.  Its message is dropped, and so is
+// its dictionary.
+func ReleaseAvpIndexer(ai *AvpIndexer) {
+	if ai == nil {
+		return
+	}
+	if ai.ix != nil && ai.ix.capacity() > maxPooledAvps {
+		*ai = AvpIndexer{}
+	} else {
+		ai.Reset(nil)
+		ai.dict = nil
+	}
+	indexerPool.Put(ai)
+}
+
+// number of AVPs ix has storage for
+func (ix *avpIndex) capacity() int {
+	n := cap(ix.nodes)
+	if ix.lazy != nil && cap(ix.lazy.avps) > n {
+		n = cap(ix.lazy.avps)
+	}
+	return n
+}
@@ -0,0 +1,183 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	other, err := NewMessage(271, 3, true).AVP(0, 263, "other").AVP(0, 480, 2).Diameter()
+	a.NilError(t, err)
+	msgs := []*Diameter{largeMessage(t, 60), d, other, nil, largeMessage(t, 3), d}
+
+	var ai AvpIndexer
+	ai = ai.WithDictionary(ai.dictionary())
+	for m, dia := range msgs {
+		ai.Reset(dia)
+		fresh := AvpIndexer{}
+		if dia != nil {
+			fresh = NewAvpIndexer(dia)
+		}
+		for _, s := range trieTestPaths {
+			path := MustParsePath(s).leaf
+			var want, got []*AVP
+			fresh.visitIntfcp(path, func(avp *AVP) { want = append(want, avp) })
+			ai.visitIntfcp(path, func(avp *AVP) { got = append(got, avp) })
+			a.Equal(t, len(got), len(want), "message %d %s", m, s)
+			for i := range want {
+				a.Equal(t, got[i], want[i], "message %d %s", m, s)
+			}
+		}
+		a.Equal(t, ai.Count(Wildcard, Wildcard), fresh.Count(Wildcard, Wildcard))
+		a.Equal(t, ai.FromGroup(0, 456).Count(0, 432), fresh.FromGroup(0, 456).Count(0, 432))
+		a.Assert(t, ai.dict != nil)
+	}
+
+	// the old message's AVPs aren't held on to
+	ai.Reset(largeMessage(t, 60))
+	ai.Reset(d)
+	for _, pe := range ai.ix.nodes[len(ai.ix.nodes):cap(ai.ix.nodes)] {
+		a.Assert(t, pe.avp == nil)
+	}
+}
+
+func TestResetStale(t *testing.T) {
+	ai := NewAvpIndexer(d)
+	cp := ai
+	sub := ai.FromGroup(10415, 873)
+	sdc := ai.Query("**/10415/2040").Groups()[0]
+	n := sub.Count(Wildcard, Wildcard)
+	a.Assert(t, n > 0)
+	a.Equal(t, sdc.GetUint32(10415, 2045), uint32(241))
+
+	// indexers made before a Reset match nothing, even when it's for the same message
+	ai.Reset(d)
+	a.Equal(t, cp.Count(Wildcard, Wildcard), 0)
+	a.Equal(t, sub.Count(Wildcard, Wildcard), 0)
+	_, err := sdc.GetUint32E(10415, 2045)
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+	a.Equal(t, qTimeUsage.GetUint32(sub), uint32(0))
+	a.Equal(t, ai.FromGroup(10415, 873).Count(Wildcard, Wildcard), n)
+
+	lazy, err := NewLazyAvpIndexer(nil, testPacketDiameterAccountingRequest271)
+	a.NilError(t, err)
+	sub = lazy.FromGroup(10415, 873)
+	a.NilError(t, lazy.ResetBytes(nil, testPacketDiameterAccountingRequest271))
+	a.Equal(t, sub.Count(Wildcard, Wildcard), 0)
+	a.Equal(t, lazy.FromGroup(10415, 873).Count(Wildcard, Wildcard), n)
+	a.Assert(t, lazy.ResetBytes(nil, []byte{1}) != nil)
+	a.Equal(t, lazy.Count(Wildcard, Wildcard), 0)
+}
+
+func TestResetAllocs(t *testing.T) {
+	small, large := d, largeMessage(t, 60)
+	var ai AvpIndexer
+	ai.Reset(large)
+	ai.BuildIndex()
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	allocs := testing.AllocsPerRun(100, func() {
+		for _, dia := range []*Diameter{small, large} {
+			ai.Reset(dia)
+			rg.Count(ai)
+			qTimeUsage.GetUint32(ai)
+			qNodeAddress.Exists(ai)
+		}
+	})
+	a.Equal(t, allocs, float64(0))
+	a.Equal(t, rg.Count(ai), 60)
+}
+
+func TestAcquireRelease(t *testing.T) {
+	ai := AcquireAvpIndexer(d)
+	a.Equal(t, qTimeUsage.GetUint32(ai), uint32(241))
+	a.Equal(t, ai.Count(0, 432), 2)
+	ReleaseAvpIndexer(ai)
+	a.Equal(t, ai.Count(Wildcard, Wildcard), 0)
+
+	ai = AcquireAvpIndexer(largeMessage(t, 60))
+	a.Equal(t, ai.FromGroup(0, 456).Count(0, 432), 60)
+	ReleaseAvpIndexer(ai)
+	ReleaseAvpIndexer(nil)
+
+	// oversized indexers give up their storage
+	ai = AcquireAvpIndexer(largeMessage(t, maxPooledAvps/8))
+	ReleaseAvpIndexer(ai)
+	a.Assert(t, ai.ix == nil)
+}
+
+func BenchmarkIndexerReuse(b *testing.B) {
+	msgs := map[string]*Diameter{"small": d, "large": largeMessage(b, 60)}
+	for _, size := range []string{"small", "large"} {
+		dia := msgs[size]
+		// a typical collector's extractions from each message
+		extract := func(ai AvpIndexer) {
+			qRecordType.GetEnumerated(ai)
+			qTimeUsage.GetUint32(ai)
+			qRatingGroup.Count(ai)
+		}
+		b.Run(size+"/new", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				extract(NewAvpIndexer(dia))
+			}
+		})
+		b.Run(size+"/reset", func(b *testing.B) {
+			var ai AvpIndexer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ai.Reset(dia)
+				extract(ai)
+			}
+		})
+		b.Run(size+"/pool", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					ai := AcquireAvpIndexer(dia)
+					extract(*ai)
+					ReleaseAvpIndexer(ai)
+				}
+			})
+		})
+	}
+}
+
+func TestResetDictionary(t *testing.T) {
+	acme := dictionary.Default().Clone()
+	acme.AddAVP(&dictionary.AVP{Name: "Acme-Id", Code: 1, VendorID: 99999, Type: dictionary.Unsigned32})
+	dia, err := NewMessage(271, 3, true).WithDictionary(acme).AVP(99999, 1, uint32(5)).Diameter()
+	a.NilError(t, err)
+
+	v, err := NewAvpIndexer(dia).GetUint32ByName("Acme-Id")
+	a.NilError(t, err)
+	a.Equal(t, v, uint32(5))
+
+	// pooled and reused indexers take the message's dictionary
+	for i := 0; i < 2; i++ {
+		pai := AcquireAvpIndexer(dia)
+		v, err = pai.GetUint32ByName("Acme-Id")
+		a.NilError(t, err)
+		a.Equal(t, v, uint32(5))
+		ReleaseAvpIndexer(pai)
+	}
+	var ai AvpIndexer
+	ai.Reset(d)
+	ai.Reset(dia)
+	v, err = ai.GetUint32ByName("Acme-Id")
+	a.NilError(t, err)
+	a.Equal(t, v, uint32(5))
+	ai.Reset(d)
+	_, err = ai.GetUint32ByName("Acme-Id")
+	a.ErrorContains(t, err, "Acme-Id")
+
+	// unless one was set with WithDictionary
+	ai = ai.WithDictionary(dictionary.New())
+	ai.Reset(dia)
+	_, err = ai.GetUint32ByName("Acme-Id")
+	a.ErrorContains(t, err, "Acme-Id")
+	a.NilError(t, ai.ResetBytes(acme, testPacketDiameterAccountingRequest271))
+	_, err = ai.GetUint32ByName("Session-Id")
+	a.ErrorContains(t, err, "Session-Id")
+}
@@ -0,0 +1,369 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+	"net"
+	"time"
+)
+
+// Compiled queries, for the same extractions run against many messages.  A Query is a parsed path whose getters
+// take the indexer to run on, so it is built once and shared:
+//
+//	var ratingGroup = MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+//	var octets = MustCompile("10415/874/10415/2040/0/412")
+//
+//	rg, ok := ratingGroup.LookupUint32(ai)
+//	all = octets.AppendUint64(ai, all[:0])
+//
+// Any Indexer will do; on a path scoped one (FromGroup, EachGroup ...) the path is taken relative to its group, as
+// by its QueryPath, unless anchored at the root.  Indexer.Query is a Query bound to one indexer.
+//
+// A Query is immutable and safe for concurrent use.  On an AvpIndexer its scalar getters, and the AppendXxx methods
+// given a slice with room, don't allocate unless they fail (the *AvpError of GetXxxE); on a scoped indexer the path
+// under its group is built per call.  The indexer's path index is built by whichever lookup comes first, see
+// BuildIndex.
+
+// Query is a compiled path, see Compile.  The zero Query matches nothing.
+type Query struct {
+	path *pathElement
+}
+
+// Compile path, given as for ParsePath or, with the bundled dictionary, ParseNamePath.
+func Compile(path string) (Query, error) {
+	return CompileWith(nil, path)
+}
+
+// Compile path, resolving AVP names with d (nil for the bundled dictionary).
+func CompileWith(d *dictionary.Dictionary, path string) (Query, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	p, err := parseTagPath(d, path)
+	if err != nil {
+		return Query{}, err
+	}
+	return CompilePath(p), nil
+}
+
+// Same as Compile but panics on error; for paths fixed at compile time.
+func MustCompile(path string) Query {
+	q, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Compile a parsed path.
+func CompilePath(p Path) Query {
+	return Query{path: p.leaf}
+}
+
+// The compiled path.
+func (q Query) Path() Path {
+	return Path{leaf: q.path}
+}
+
+// Path in the slash separated form accepted by ParsePath.
+func (q Query) String() string {
+	return q.Path().String()
+}
+
+// indexer and path to run q on for ix: q's path placed under ix's group, as Indexer.QueryPath does.  The type switch
+// rather than ix.scope() keeps ix from escaping, so passing an AvpIndexer doesn't allocate.
+func (q Query) on(ix Indexer) (AvpIndexer, *pathElement) {
+	if q.path == nil {
+		return AvpIndexer{}, nil
+	}
+	switch ix := ix.(type) {
+	case AvpIndexer:
+		return ix, q.path
+	case *AvpIndexer:
+		return *ix, q.path
+	case avpIndexerWithPath:
+		return ix.AvpIndexer, q.path.under(ix.parent)
+	case *avpIndexerWithPath:
+		return ix.AvpIndexer, q.path.under(ix.parent)
+	}
+	return AvpIndexer{}, nil
+}
+
+// decoder of avp if it is a D whose data decoded
+func decoderAs[D DiameterDecoder](avp *AVP) (D, bool) {
+	var none D
+	if avp == nil {
+		return none, false
+	}
+	dec, ok := avp.GetDecoder().(D)
+	if !ok || avp.decodeErr != nil {
+		return none, false
+	}
+	return dec, true
+}
+
+// number of matching AVPs
+func (q Query) Count(ix Indexer) int {
+	ai, path := q.on(ix)
+	var n int
+	ai.scan(path, false, func(*pathElementLeafNode) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// true if at least one AVP matches
+func (q Query) Exists(ix Indexer) bool {
+	ai, path := q.on(ix)
+	return ai.firstAvp(path) != nil
+}
+
+// first matching AVP in message order, or nil
+func (q Query) First(ix Indexer) *AVP {
+	ai, path := q.on(ix)
+	return ai.firstAvp(path)
+}
+
+// last matching AVP in message order, or nil
+func (q Query) Last(ix Indexer) *AVP {
+	ai, path := q.on(ix)
+	return ai.lastAvp(path)
+}
+
+// i'th matching AVP (0 based) in message order, or nil if there are not that many
+func (q Query) Nth(ix Indexer, i int) *AVP {
+	ai, path := q.on(ix)
+	return ai.nthAvp(path, i)
+}
+
+// invoke f for each matching AVP.  returns number of times f was invoked.
+func (q Query) VisitAvp(ix Indexer, f func(avp *AVP)) int {
+	ai, path := q.on(ix)
+	var n int
+	ai.scan(path, false, func(pe *pathElementLeafNode) bool {
+		n++
+		f(pe.avp)
+		return true
+	})
+	return n
+}
+
+// retrieve first matching uint32 value, or the default/zero value for that type
+func (q Query) GetUint32(ix Indexer) uint32 {
+	return getValue[*DiameterUnsigned32, uint32](q.on(ix))
+}
+
+// retrieve first matching uint32 value; false if there is no such AVP or it can't be read as uint32
+func (q Query) LookupUint32(ix Indexer) (uint32, bool) {
+	return lookupValue[*DiameterUnsigned32, uint32](q.on(ix))
+}
+
+// retrieve first matching uint32 value, or an *AvpError and the zero value for that type
+func (q Query) GetUint32E(ix Indexer) (uint32, error) {
+	return valueE[*DiameterUnsigned32, uint32](q.on(ix))
+}
+
+// append uint32 values of all matching AVPs to dst, in message order; AVPs that can't be read as uint32 are
+// left out
+func (q Query) AppendUint32(ix Indexer, dst []uint32) []uint32 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterUnsigned32, uint32](ai, path, dst)
+}
+
+// retrieve first matching enumerated (uint32) value, or the default/zero value for that type
+func (q Query) GetEnumerated(ix Indexer) uint32 {
+	return getValue[*DiameterEnumerated, uint32](q.on(ix))
+}
+
+// retrieve first matching enumerated (uint32) value; false if there is no such AVP or it can't be read as enumerated
+func (q Query) LookupEnumerated(ix Indexer) (uint32, bool) {
+	return lookupValue[*DiameterEnumerated, uint32](q.on(ix))
+}
+
+// retrieve first matching enumerated (uint32) value, or an *AvpError and the zero value for that type
+func (q Query) GetEnumeratedE(ix Indexer) (uint32, error) {
+	return valueE[*DiameterEnumerated, uint32](q.on(ix))
+}
+
+// append enumerated (uint32) values of all matching AVPs to dst, in message order; AVPs that can't be read as
+// enumerated are left out
+func (q Query) AppendEnumerated(ix Indexer, dst []uint32) []uint32 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterEnumerated, uint32](ai, path, dst)
+}
+
+// retrieve first matching uint64 value, or the default/zero value for that type
+func (q Query) GetUint64(ix Indexer) uint64 {
+	return getValue[*DiameterUnsigned64, uint64](q.on(ix))
+}
+
+// retrieve first matching uint64 value; false if there is no such AVP or it can't be read as uint64
+func (q Query) LookupUint64(ix Indexer) (uint64, bool) {
+	return lookupValue[*DiameterUnsigned64, uint64](q.on(ix))
+}
+
+// retrieve first matching uint64 value, or an *AvpError and the zero value for that type
+func (q Query) GetUint64E(ix Indexer) (uint64, error) {
+	return valueE[*DiameterUnsigned64, uint64](q.on(ix))
+}
+
+// append uint64 values of all matching AVPs to dst, in message order; AVPs that can't be read as uint64 are
+// left out
+func (q Query) AppendUint64(ix Indexer, dst []uint64) []uint64 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterUnsigned64, uint64](ai, path, dst)
+}
+
+// retrieve first matching int32 value, or the default/zero value for that type
+func (q Query) GetInt32(ix Indexer) int32 {
+	return getValue[*DiameterInteger32, int32](q.on(ix))
+}
+
+// retrieve first matching int32 value; false if there is no such AVP or it can't be read as int32
+func (q Query) LookupInt32(ix Indexer) (int32, bool) {
+	return lookupValue[*DiameterInteger32, int32](q.on(ix))
+}
+
+// retrieve first matching int32 value, or an *AvpError and the zero value for that type
+func (q Query) GetInt32E(ix Indexer) (int32, error) {
+	return valueE[*DiameterInteger32, int32](q.on(ix))
+}
+
+// append int32 values of all matching AVPs to dst, in message order; AVPs that can't be read as int32 are
+// left out
+func (q Query) AppendInt32(ix Indexer, dst []int32) []int32 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterInteger32, int32](ai, path, dst)
+}
+
+// retrieve first matching int64 value, or the default/zero value for that type
+func (q Query) GetInt64(ix Indexer) int64 {
+	return getValue[*DiameterInteger64, int64](q.on(ix))
+}
+
+// retrieve first matching int64 value; false if there is no such AVP or it can't be read as int64
+func (q Query) LookupInt64(ix Indexer) (int64, bool) {
+	return lookupValue[*DiameterInteger64, int64](q.on(ix))
+}
+
+// retrieve first matching int64 value, or an *AvpError and the zero value for that type
+func (q Query) GetInt64E(ix Indexer) (int64, error) {
+	return valueE[*DiameterInteger64, int64](q.on(ix))
+}
+
+// append int64 values of all matching AVPs to dst, in message order; AVPs that can't be read as int64 are
+// left out
+func (q Query) AppendInt64(ix Indexer, dst []int64) []int64 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterInteger64, int64](ai, path, dst)
+}
+
+// retrieve first matching float32 value, or the default/zero value for that type
+func (q Query) GetFloat32(ix Indexer) float32 {
+	return getValue[*DiameterFloat32, float32](q.on(ix))
+}
+
+// retrieve first matching float32 value; false if there is no such AVP or it can't be read as float32
+func (q Query) LookupFloat32(ix Indexer) (float32, bool) {
+	return lookupValue[*DiameterFloat32, float32](q.on(ix))
+}
+
+// retrieve first matching float32 value, or an *AvpError and the zero value for that type
+func (q Query) GetFloat32E(ix Indexer) (float32, error) {
+	return valueE[*DiameterFloat32, float32](q.on(ix))
+}
+
+// append float32 values of all matching AVPs to dst, in message order; AVPs that can't be read as float32 are
+// left out
+func (q Query) AppendFloat32(ix Indexer, dst []float32) []float32 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterFloat32, float32](ai, path, dst)
+}
+
+// retrieve first matching float64 value, or the default/zero value for that type
+func (q Query) GetFloat64(ix Indexer) float64 {
+	return getValue[*DiameterFloat64, float64](q.on(ix))
+}
+
+// retrieve first matching float64 value; false if there is no such AVP or it can't be read as float64
+func (q Query) LookupFloat64(ix Indexer) (float64, bool) {
+	return lookupValue[*DiameterFloat64, float64](q.on(ix))
+}
+
+// retrieve first matching float64 value, or an *AvpError and the zero value for that type
+func (q Query) GetFloat64E(ix Indexer) (float64, error) {
+	return valueE[*DiameterFloat64, float64](q.on(ix))
+}
+
+// append float64 values of all matching AVPs to dst, in message order; AVPs that can't be read as float64 are
+// left out
+func (q Query) AppendFloat64(ix Indexer, dst []float64) []float64 {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterFloat64, float64](ai, path, dst)
+}
+
+// retrieve first matching time.Time value, or the default/zero value for that type
+func (q Query) GetTime(ix Indexer) time.Time {
+	return getValue[*DiameterTime, time.Time](q.on(ix))
+}
+
+// retrieve first matching time.Time value; false if there is no such AVP or it can't be read as time.Time
+func (q Query) LookupTime(ix Indexer) (time.Time, bool) {
+	return lookupValue[*DiameterTime, time.Time](q.on(ix))
+}
+
+// retrieve first matching time.Time value, or an *AvpError and the zero value for that type
+func (q Query) GetTimeE(ix Indexer) (time.Time, error) {
+	return valueE[*DiameterTime, time.Time](q.on(ix))
+}
+
+// append time.Time values of all matching AVPs to dst, in message order; AVPs that can't be read as time.Time are
+// left out
+func (q Query) AppendTime(ix Indexer, dst []time.Time) []time.Time {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterTime, time.Time](ai, path, dst)
+}
+
+// retrieve first matching string value, or the default/zero value for that type
+func (q Query) GetUTF8String(ix Indexer) string {
+	return getValue[*DiameterOctetString, string](q.on(ix))
+}
+
+// retrieve first matching string value; false if there is no such AVP or it can't be read as string
+func (q Query) LookupUTF8String(ix Indexer) (string, bool) {
+	return lookupValue[*DiameterOctetString, string](q.on(ix))
+}
+
+// retrieve first matching string value, or an *AvpError and the zero value for that type
+func (q Query) GetUTF8StringE(ix Indexer) (string, error) {
+	return valueE[*DiameterOctetString, string](q.on(ix))
+}
+
+// append string values of all matching AVPs to dst, in message order; AVPs that can't be read as string are
+// left out
+func (q Query) AppendUTF8String(ix Indexer, dst []string) []string {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterOctetString, string](ai, path, dst)
+}
+
+// retrieve first matching net.IP value, or the default/zero value for that type
+func (q Query) GetIPAddress(ix Indexer) net.IP {
+	return getValue[*DiameterIPAddress, net.IP](q.on(ix))
+}
+
+// retrieve first matching net.IP value; false if there is no such AVP or it can't be read as net.IP
+func (q Query) LookupIPAddress(ix Indexer) (net.IP, bool) {
+	return lookupValue[*DiameterIPAddress, net.IP](q.on(ix))
+}
+
+// retrieve first matching net.IP value, or an *AvpError and the zero value for that type
+func (q Query) GetIPAddressE(ix Indexer) (net.IP, error) {
+	return valueE[*DiameterIPAddress, net.IP](q.on(ix))
+}
+
+// append net.IP values of all matching AVPs to dst, in message order; AVPs that can't be read as net.IP are
+// left out
+func (q Query) AppendIPAddress(ix Indexer, dst []net.IP) []net.IP {
+	ai, path := q.on(ix)
+	return appendValues[*DiameterIPAddress, net.IP](ai, path, dst)
+}
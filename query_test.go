@@ -0,0 +1,193 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	qRecordType  = MustCompile("0/480")
+	qRatingGroup = MustCompile("10415/874/10415/2040/0/432")
+	qTimeUsage   = MustCompile("Service-Information/PS-Information/Service-Data-Container/Time-Usage")
+	qChargingId  = MustCompile("10415/874/10415/2")
+	qNodeAddress = MustCompile("**/10415/1228")
+	qSessionId   = MustCompile("/Session-Id")
+	qEventTime   = MustCompile("Event-Timestamp")
+)
+
+func TestCompiledQuery(t *testing.T) {
+	ai := NewAvpIndexer(d)
+	a.Equal(t, qRecordType.GetEnumerated(ai), uint32(4))
+	a.Equal(t, qRatingGroup.GetUint32(ai), ai.FromGroup(10415, 874).FromGroup(10415, 2040).GetUint32(0, 432))
+	a.Equal(t, qTimeUsage.GetUint32(ai), uint32(241))
+	a.Equal(t, qChargingId.GetUint32(ai), uint32(0x5e9ed913))
+	a.Equal(t, qNodeAddress.GetIPAddress(ai).String(), "78.147.12.161")
+	a.Equal(t, qSessionId.GetUTF8String(ai), ai.GetUTF8String(0, 263))
+	a.Equal(t, qEventTime.GetTime(ai), ai.GetTime(0, 55))
+	a.Equal(t, qTimeUsage.String(), "10415/873/10415/874/10415/2040/10415/2045")
+
+	a.Equal(t, qRatingGroup.Count(ai), 2)
+	a.DeepEqual(t, qRatingGroup.AppendUint32(ai, nil), []uint32{0, 4001})
+	a.Equal(t, qRatingGroup.Last(ai), ai.Query("10415/874/10415/2040/0/432").Last())
+	a.Equal(t, qRatingGroup.Nth(ai, 1), qRatingGroup.Last(ai))
+	a.Assert(t, qRatingGroup.Exists(ai))
+
+	// the same queries on another message
+	other, err := NewMessage(271, 3, true).AVP(0, 263, "other").AVP(0, 480, 2).Diameter()
+	a.NilError(t, err)
+	oi := NewAvpIndexer(other)
+	a.Equal(t, qRecordType.GetEnumerated(oi), uint32(2))
+	a.Equal(t, qSessionId.GetUTF8String(oi), "other")
+	_, ok := qRatingGroup.LookupUint32(oi)
+	a.Assert(t, !ok)
+
+	// failures
+	_, err = qRatingGroup.GetUint32E(oi)
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+	a.ErrorContains(t, err, "10415/874/10415/2040/0/432")
+	a.Equal(t, qSessionId.GetUint32(ai), uint32(0))
+	_, err = qSessionId.GetUint32E(ai)
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+	a.Equal(t, Query{}.Count(ai), 0)
+	a.Equal(t, Query{}.GetUint32(AvpIndexer{}), uint32(0))
+
+	_, err = Compile("10415/x")
+	a.ErrorContains(t, err, "invalid avp path")
+	_, err = Compile("No-Such-AVP")
+	a.ErrorContains(t, err, "No-Such-AVP")
+
+	// names from another dictionary
+	dict := dictionary.Default().Clone()
+	dict.AddAVP(&dictionary.AVP{Name: "Acme-Record-Type", Code: 480, Type: dictionary.Enumerated})
+	acme, err := CompileWith(dict, "Acme-Record-Type")
+	a.NilError(t, err)
+	a.Equal(t, acme.GetEnumerated(ai), uint32(4))
+	_, err = Compile("Acme-Record-Type")
+	a.ErrorContains(t, err, "Acme-Record-Type")
+}
+
+func TestCompiledQueryScoped(t *testing.T) {
+	ai := NewAvpIndexer(d)
+	rg := MustCompile("0/432")
+	ps := ai.FromGroup(10415, 874)
+	a.DeepEqual(t, rg.AppendUint32(ps.FromGroup(10415, 2040), nil), []uint32{0, 4001})
+	a.Equal(t, rg.Count(ps.AtRoot()), 0)
+	a.Equal(t, qSessionId.GetUTF8String(ps), ai.GetUTF8String(0, 263)) // anchored, the scope doesn't apply
+	a.Equal(t, qTimeUsage.GetUint32(&ai), uint32(241))
+
+	var rgs []uint32
+	ps.EachGroup(10415, 2040, func(sdc Indexer) {
+		rgs = append(rgs, rg.GetUint32(sdc))
+		a.Equal(t, rg.Count(sdc), 1)
+	})
+	a.DeepEqual(t, rgs, []uint32{0, 4001})
+	a.Equal(t, ai.Query("0/432").Count(), rg.Count(ai))
+}
+
+func TestCompiledQueryAllocs(t *testing.T) {
+	ai := NewAvpIndexer(largeMessage(t, 60))
+	rg := MustCompile("Multiple-Services-Credit-Control/Rating-Group")
+	octets := MustCompile("**/CC-Input-Octets")
+	ts := MustCompile("/Origin-Host")
+	rgs := make([]uint32, 0, 100)
+	allocs := testing.AllocsPerRun(100, func() {
+		rg.GetUint32(ai)
+		octets.LookupUint64(ai)
+		ts.GetUTF8String(ai)
+		if _, err := rg.GetUint32E(ai); err != nil {
+			t.Fatal(err)
+		}
+		rg.Count(ai)
+		rgs = rg.AppendUint32(ai, rgs[:0])
+	})
+	a.Equal(t, allocs, float64(0))
+	a.Equal(t, len(rgs), 60)
+}
+
+func TestCompiledQueryConcurrent(t *testing.T) {
+	// the index is built by whichever goroutine gets there first
+	ai := NewAvpIndexer(d)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if qTimeUsage.GetUint32(ai) != 241 || qRatingGroup.Count(ai) != 2 {
+					t.Error("wrong result")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCompiledQuery(b *testing.B) {
+	ai := NewAvpIndexer(largeMessage(b, 60)).BuildIndex()
+	b.Run("compiled", func(b *testing.B) {
+		q := MustCompile("0/456/0/446/0/412")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q.GetUint64(ai)
+		}
+	})
+	b.Run("path", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ai.Query("0/456/0/446/0/412").GetUint64()
+		}
+	})
+	b.Run("FromGroup", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ai.FromGroup(0, 456).FromGroup(0, 446).GetUint64(0, 412)
+		}
+	})
+	b.Run("compiled/E", func(b *testing.B) {
+		q := MustCompile("0/456/0/446/0/412")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q.GetUint64E(ai)
+		}
+	})
+	b.Run("LookupE", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ai.FromGroup(0, 456).FromGroup(0, 446).GetUint64E(0, 412)
+		}
+	})
+	b.Run("compiled/Append", func(b *testing.B) {
+		q := MustCompile("0/456/0/432")
+		var vs []uint32
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			vs = q.AppendUint32(ai, vs[:0])
+		}
+	})
+	b.Run("GetAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ai.FromGroup(0, 456).GetAllUint32(0, 432)
+		}
+	})
+
+	// a message's worth of extractions, index built per message
+	qs := []Query{qRecordType, qRatingGroup, qTimeUsage, qChargingId, qNodeAddress, qSessionId, qEventTime}
+	b.Run("perMessage", func(b *testing.B) {
+		b.ReportAllocs()
+		var tm time.Time
+		for i := 0; i < b.N; i++ {
+			ai := NewAvpIndexer(d)
+			for _, q := range qs {
+				q.GetUint32(ai)
+			}
+			tm = qEventTime.GetTime(ai)
+		}
+		_ = tm
+	})
+}
@@ -0,0 +1,128 @@
+package avpindexer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Tabular extraction from repeating groups: one row per group instance (see EachGroup), one column per path relative
+// to the group.  e.g. per Service-Data-Container usage:
+//
+//	rows, err := Table(ai, "10415/874/10415/2040", "0/432", "0/363", "0/364", "10415/2043")
+//
+// or into a struct slice, with column paths in `avp` field tags:
+//
+//	type usage struct {
+//		RatingGroup  uint32     `avp:"0/432"`
+//		InputOctets  uint64     `avp:"0/363"`
+//		OutputOctets uint64     `avp:"0/364"`
+//		FirstUsage   *time.Time `avp:"10415/2043"`
+//	}
+//	rows, err := TableOf[usage](ai, "10415/874/10415/2040")
+
+// Return one row per grouped AVP instance matching group, holding the value of the first AVP matching each of the
+// column paths in that instance, or nil where there is no such AVP or it can't be decoded.  Values have the Go type
+// of the AVP's decoder: uint32, uint64, int32, int64, float32, float64, time.Time, string or net.IP.
+func Table(ix Indexer, group string, columns ...string) ([][]interface{}, error) {
+	cols := make([]Path, len(columns))
+	for i, c := range columns {
+		p, err := ParsePath(c)
+		if err != nil {
+			return nil, err
+		}
+		cols[i] = p
+	}
+	q := ix.Query(group)
+	if q.Err() != nil {
+		return nil, q.Err()
+	}
+
+	var rows [][]interface{}
+	q.EachGroup(func(sub Indexer) {
+		row := make([]interface{}, len(cols))
+		for i, c := range cols {
+			if avp := sub.QueryPath(c).First(); avp != nil {
+				row[i] = decodedValue(avp)
+			}
+		}
+		rows = append(rows, row)
+	})
+	return rows, nil
+}
+
+// value of a non grouped AVP, typed according to its decoder; nil if it can't be decoded
+func decodedValue(avp *AVP) interface{} {
+	if len(avp.Grouped) > 0 {
+		return nil
+	}
+	// a lazily decoded AVP has no decodeErr until GetDecoder has decoded it
+	dec := avp.GetDecoder()
+	if avp.decodeErr != nil {
+		return nil
+	}
+	switch dec := dec.(type) {
+	case *DiameterUnsigned32:
+		return dec.Get()
+	case *DiameterEnumerated:
+		return dec.Get()
+	case *DiameterUnsigned64:
+		return dec.Get()
+	case *DiameterInteger32:
+		return dec.Get()
+	case *DiameterInteger64:
+		return dec.Get()
+	case *DiameterFloat32:
+		return dec.Get()
+	case *DiameterFloat64:
+		return dec.Get()
+	case *DiameterTime:
+		return dec.Get()
+	case *DiameterOctetString:
+		return dec.Get()
+	case *DiameterIPAddress:
+		return dec.Get()
+	}
+	return nil
+}
+
+// Return one T per grouped AVP instance matching group.  T must be a struct whose tagged fields are filled from that
+// instance as Unmarshal fills them: `avp:"path"` (ids or names) relative to the group, registered types and named
+// types over them (see RegisterType), pointers for optional AVPs, slices for repeated ones and structs for grouped
+// ones.  A missing AVP leaves the field at its zero value; an AVP that can't be decoded as the field's type is an
+// error.
+func TableOf[T any](ix Indexer, group string) ([]T, error) {
+	rt := reflect.TypeOf((*T)(nil)).Elem()
+	if rt.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("TableOf: %s is not a struct", rt)
+	}
+	ai, _ := ix.scope()
+	d := ai.dictionary()
+	fields, err := tagFields(d, rt, rt.Name())
+	if err != nil {
+		return nil, fmt.Errorf("TableOf: %w", err)
+	}
+
+	q := ix.Query(group)
+	if q.Err() != nil {
+		return nil, q.Err()
+	}
+
+	var rows []T
+	q.EachGroup(func(sub Indexer) {
+		if err != nil {
+			return
+		}
+		var row T
+		rv := reflect.ValueOf(&row).Elem()
+		for _, f := range fields {
+			if err = unmarshalField(sub.QueryPath(f.path), d, rv.Field(f.index), f.name); err != nil {
+				return
+			}
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("TableOf: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,112 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"testing"
+	"time"
+)
+
+func TestTable(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	rows, err := Table(ai, "10415/874/10415/2040", "0/432", "0/363", "0/364", "10415/2043", "0/999")
+	a.NilError(t, err)
+	a.Equal(t, len(rows), 2)
+	a.DeepEqual(t, rows[0][:3], []interface{}{uint32(0), uint64(500), uint64(3208)})
+	a.DeepEqual(t, rows[1][:3], []interface{}{uint32(4001), uint64(13492), uint64(26694)})
+	a.Equal(t, rows[0][3], ai.FromGroup(10415, 2040).GetTime(10415, 2043))
+	a.Assert(t, rows[0][4] == nil)
+	a.Assert(t, rows[1][4] == nil)
+
+	// relative to a path scoped indexer
+	rows, err = Table(ai.FromGroup(10415, 873), "**/10415/2040", "0/432")
+	a.NilError(t, err)
+	a.DeepEqual(t, rows, [][]interface{}{{uint32(0)}, {uint32(4001)}})
+
+	rows, err = Table(ai, "0/999", "0/432")
+	a.NilError(t, err)
+	a.Equal(t, len(rows), 0)
+
+	_, err = Table(ai, "10415/2040", "0/x")
+	a.ErrorContains(t, err, "invalid avp path")
+}
+
+func TestTableDecodeFailure(t *testing.T) {
+	// a 3 byte Rating-Group reads as nil, whether decoded up front or lazily
+	mscc := testAvp(t, 456, 0x40, 0, testAvp(t, 432, 0x40, 0, []byte{0, 0, 1}))
+	b, err := appendMessage(nil, 1, 0, 272, 4, 1, 2, mscc)
+	a.NilError(t, err)
+	eager, err := Decode(nil, b)
+	a.NilError(t, err)
+	lazy, err := NewLazyAvpIndexer(nil, b)
+	a.NilError(t, err)
+	for name, ai := range map[string]AvpIndexer{"eager": NewAvpIndexer(eager), "lazy": lazy} {
+		rows, err := Table(ai, "0/456", "0/432")
+		a.NilError(t, err, name)
+		a.DeepEqual(t, rows, [][]interface{}{{nil}})
+		_, err = ai.GetUint32E(0, 432)
+		a.Assert(t, errors.Is(err, ErrAvpDecode), name)
+	}
+}
+
+type testUsageRow struct {
+	RatingGroup  uint32     `avp:"0/432"`
+	InputOctets  uint64     `avp:"0/363"`
+	OutputOctets uint64     `avp:"0/364"`
+	FirstUsage   *time.Time `avp:"10415/2043"`
+	Missing      *uint32    `avp:"0/999"`
+	Ignored      string
+}
+
+func TestTableOf(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	rows, err := TableOf[testUsageRow](ai, "10415/874/10415/2040")
+	a.NilError(t, err)
+	a.Equal(t, len(rows), 2)
+	a.Equal(t, rows[1].RatingGroup, uint32(4001))
+	a.Equal(t, rows[1].InputOctets, uint64(13492))
+	a.Equal(t, rows[1].OutputOctets, uint64(26694))
+	a.Equal(t, *rows[0].FirstUsage, ai.FromGroup(10415, 2040).GetTime(10415, 2043))
+	a.Assert(t, rows[0].Missing == nil)
+
+	type badType struct {
+		RatingGroup time.Time `avp:"0/432"`
+	}
+	_, err = TableOf[badType](ai, "10415/2040")
+	a.Assert(t, errors.Is(err, ErrAvpTypeMismatch))
+
+	type badPath struct {
+		RatingGroup uint32 `avp:"432"`
+	}
+	_, err = TableOf[badPath](ai, "10415/2040")
+	a.ErrorContains(t, err, "field badPath.RatingGroup")
+
+	// unexported fields and unknown tag options are errors, as for Unmarshal, rather than panics or ignored
+	type unexported struct {
+		rg uint32 `avp:"0/432"`
+	}
+	_, err = TableOf[unexported](ai, "10415/2040")
+	a.ErrorContains(t, err, "field unexported.rg is not exported")
+	type badOption struct {
+		RatingGroup uint32 `avp:"0/432,omitemtpy"`
+	}
+	_, err = TableOf[badOption](ai, "10415/2040")
+	a.ErrorContains(t, err, `unknown tag option "omitemtpy"`)
+
+	// named types, optional tag options and repeated AVPs are read as Unmarshal reads them
+	type plan uint32
+	type named struct {
+		RatingGroup plan     `avp:"Rating-Group,omitempty"`
+		Octets      []uint64 `avp:"0/364"`
+	}
+	nrows, err := TableOf[named](ai, "10415/2040")
+	a.NilError(t, err)
+	a.Equal(t, len(nrows), 2)
+	a.Equal(t, nrows[1].RatingGroup, plan(4001))
+	a.DeepEqual(t, nrows[1].Octets, []uint64{26694})
+
+	_, err = TableOf[int](ai, "10415/2040")
+	a.ErrorContains(t, err, "not a struct")
+}
@@ -0,0 +1,297 @@
+package avpindexer
+
+import (
+	"github.com/rjm2718/avpindexer/dictionary"
+	"sync"
+)
+
+// Path index.  NewAvpIndexer only records the message's AVPs in message order; for messages of more than
+// linearScanAvps AVPs, the first lookup builds a trie of the distinct id paths found in the message, each trie node
+// listing the AVPs at its path.  A lookup matches its query path against trie nodes rather than AVP instances, so
+// the 50 Rating-Groups of 50 MSCCs are matched once (as 0/456/0/432), not 50 times.  Smaller messages, most CCRs and
+// ACRs, are scanned AVP by AVP instead: the scan costs less than building the trie does, see BenchmarkIndexLookup.
+// Lookups scoped to one grouped AVP instance (EachGroup, Groups) don't need the trie either, they only scan that
+// instance's sub-AVPs.
+
+// AVPs of one message, shared by all indexers made from the same NewAvpIndexer call
+type avpIndex struct {
+	nodes  []pathElementLeafNode // every AVP in message order, sub-AVPs right after their group
+	groups []pathElement         // slab for the nodes' group elements
+	once   sync.Once
+	trie   pathTrie
+	lazy   *lazyStore             // AVPs of the message given to ResetBytes
+	gen    uint32                 // incremented by each reset, so indexers made for the previous message can tell
+	dict   *dictionary.Dictionary // the message was decoded with, nil if unknown
+}
+
+// distinct id paths of a message.  All of its storage is kept across Reset.
+type pathTrie struct {
+	nodes []trieNode          // slab, parents before children; scanned for wildcard ids
+	byId  map[avpId]*trieNode // by the id the path ends with, other nodes with that id linked by sameId
+	kids  map[trieKey]int32   // slab index by parent and id
+	at    []int32             // slab index of each AVP's trie node
+	count []int               // AVPs per trie node
+	pos   []int32             // backs every trie node's avps
+}
+
+type trieNode struct {
+	pathElement           // id path; parent is the enclosing group's trie node, nil at the message root
+	avps        []int32   // positions in avpIndex.nodes of the AVPs at this path, message order
+	sameId      *trieNode // next node whose path ends with the same id
+}
+
+type trieKey struct {
+	parent *trieNode
+	id     avpId
+}
+
+func numAvps(avps []*AVP) int {
+	n := len(avps)
+	for _, avp := range avps {
+		n += numAvps(avp.Grouped)
+	}
+	return n
+}
+
+func newAvpIndex(avps []*AVP) *avpIndex {
+	ix := &avpIndex{}
+	ix.reset(avps)
+	return ix
+}
+
+// index avps, reusing ix's storage where it's big enough
+func (ix *avpIndex) reset(avps []*AVP) {
+	// drop the previous message's AVPs so they can be collected
+	for i := range ix.nodes {
+		ix.nodes[i] = pathElementLeafNode{}
+	}
+	n := numAvps(avps)
+	ix.nodes = resize(ix.nodes, n)[:0]
+	ix.groups = resize(ix.groups, n)
+	groups := ix.groups
+	for _, avp := range avps {
+		groups = ix.add(nil, avp, groups)
+	}
+	ix.once = sync.Once{}
+	ix.gen++
+}
+
+// s with length n, reallocated only if it's too small; the contents are left as they are
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
+
+// index avp and its sub-AVPs; groups holds the parent elements for the AVPs still to be added
+func (ix *avpIndex) add(parent *pathElement, avp *AVP, groups []pathElement) []pathElement {
+	pe := &groups[0]
+	*pe = pathElement{
+		avpId:  avpId{vendorId: avp.VendorCode, attrId: avp.AttributeCode},
+		parent: parent,
+		pos:    len(ix.nodes),
+	}
+	groups = groups[1:]
+
+	// nodes are recorded before descending so that scans of ix.nodes see AVPs in message order
+	ix.nodes = append(ix.nodes, pathElementLeafNode{avp: avp, group: pe})
+	for _, sub := range avp.Grouped {
+		groups = ix.add(pe, sub, groups)
+	}
+	pe.end = len(ix.nodes)
+	ix.nodes[pe.pos].pathElement = *pe
+	return groups
+}
+
+// messages with at most this many AVPs, groups and sub-AVPs included, are looked up without the trie
+const linearScanAvps = 128
+
+// true if lookups in ix scan its AVPs rather than the trie
+func (ix *avpIndex) linear() bool {
+	return len(ix.nodes) <= linearScanAvps
+}
+
+// trie of ix's paths, built by the first caller
+func (ix *avpIndex) pathTrie() *pathTrie {
+	ix.once.Do(ix.buildTrie)
+	return &ix.trie
+}
+
+func (ix *avpIndex) buildTrie() {
+	t := &ix.trie
+	n := len(ix.nodes)
+	// no more trie nodes than AVPs, so the slab never moves
+	t.nodes = resize(t.nodes, n)[:0]
+	t.count = resize(t.count, n)[:0]
+	t.at = resize(t.at, n)
+	t.pos = resize(t.pos, n)
+	if t.kids == nil {
+		t.kids = make(map[trieKey]int32)
+		t.byId = make(map[avpId]*trieNode)
+	}
+	for k := range t.kids {
+		delete(t.kids, k)
+	}
+	for k := range t.byId {
+		delete(t.byId, k)
+	}
+
+	for i := range ix.nodes {
+		pe := &ix.nodes[i]
+		var parent *trieNode
+		if pe.parent != nil {
+			parent = &t.nodes[t.at[pe.parent.pos]]
+		}
+		k := trieKey{parent: parent, id: pe.avpId}
+		s, ok := t.kids[k]
+		if !ok {
+			s = int32(len(t.nodes))
+			t.nodes = append(t.nodes, trieNode{pathElement: pathElement{avpId: pe.avpId}})
+			t.count = append(t.count, 0)
+			tn := &t.nodes[s]
+			if parent != nil {
+				tn.parent = &parent.pathElement
+			}
+			t.kids[k] = s
+			tn.sameId = t.byId[pe.avpId]
+			t.byId[pe.avpId] = tn
+		}
+		t.at[i] = s
+		t.count[s]++
+	}
+
+	// one array for all position lists
+	var off int
+	for i := range t.nodes {
+		c := t.count[i]
+		t.nodes[i].avps = t.pos[off : off : off+c]
+		off += c
+	}
+	for i, s := range t.at {
+		t.nodes[s].avps = append(t.nodes[s].avps, int32(i))
+	}
+}
+
+// append to hits the trie nodes matching path
+func (t *pathTrie) appendMatches(hits []*trieNode, path *pathElement) []*trieNode {
+	if path.avpId.hasWildcard() {
+		for i := range t.nodes {
+			if tn := &t.nodes[i]; tn.matches(path) {
+				hits = append(hits, tn)
+			}
+		}
+		return hits
+	}
+	for tn := t.byId[path.avpId]; tn != nil; tn = tn.sameId {
+		if tn.matches(path) {
+			hits = append(hits, tn)
+		}
+	}
+	return hits
+}
+
+// most lookups match one trie node, a few more for wildcards or '**'; larger sets spill to the heap
+const scanHits = 8
+
+// invoke f for each AVP matching path, in message order (reverse order if backward), until f returns false
+func (ai AvpIndexer) scan(path *pathElement, backward bool, f func(pe *pathElementLeafNode) bool) {
+	ix := ai.ix
+	if ix == nil || ix.gen != ai.gen || path == nil {
+		return
+	}
+	if lo, hi, ok := ix.instanceRange(path); ok {
+		ix.scanRange(lo, hi, path, backward, f)
+		return
+	}
+	if ix.linear() {
+		ix.scanRange(0, len(ix.nodes), path, backward, f)
+		return
+	}
+	ix.scanTrie(path, backward, f)
+}
+
+// invoke f for each AVP matching path, as for scan, through the trie
+func (ix *avpIndex) scanTrie(path *pathElement, backward bool, f func(pe *pathElementLeafNode) bool) {
+	var buf [scanHits]*trieNode
+	hits := ix.pathTrie().appendMatches(buf[:0], path)
+	if len(hits) == 1 {
+		avps := hits[0].avps
+		for i := range avps {
+			j := i
+			if backward {
+				j = len(avps) - 1 - i
+			}
+			if !f(&ix.nodes[avps[j]]) {
+				return
+			}
+		}
+		return
+	}
+
+	// merge the hits' position lists; they're short, so the next position is found by a linear search
+	var cbuf [scanHits]int
+	cursor := cbuf[:0]
+	for _, tn := range hits {
+		if backward {
+			cursor = append(cursor, len(tn.avps)-1)
+		} else {
+			cursor = append(cursor, 0)
+		}
+	}
+	for {
+		best := -1
+		for h, tn := range hits {
+			c := cursor[h]
+			if c < 0 || c >= len(tn.avps) {
+				continue
+			}
+			if best < 0 || (tn.avps[c] < hits[best].avps[cursor[best]]) != backward {
+				best = h
+			}
+		}
+		if best < 0 {
+			return
+		}
+		p := hits[best].avps[cursor[best]]
+		if backward {
+			cursor[best]--
+		} else {
+			cursor[best]++
+		}
+		if !f(&ix.nodes[p]) {
+			return
+		}
+	}
+}
+
+// invoke f for each AVP at positions lo to hi in ix.nodes that matches path, as for scan
+func (ix *avpIndex) scanRange(lo, hi int, path *pathElement, backward bool, f func(pe *pathElementLeafNode) bool) {
+	for i := lo; i < hi; i++ {
+		j := i
+		if backward {
+			j = hi - 1 - (i - lo)
+		}
+		// ids first, most AVPs fail there
+		pe := &ix.nodes[j]
+		if path.matchesId(pe.avpId) && aboveMatches(pe.parent, path.parent) && !f(pe) {
+			return
+		}
+	}
+}
+
+// positions of the AVPs that can match path if it's scoped to one grouped AVP instance (kindNode); an instance
+// from some other message matches nothing
+func (ix *avpIndex) instanceRange(path *pathElement) (int, int, bool) {
+	for e := path; e != nil; e = e.parent {
+		if e.kind != kindNode {
+			continue
+		}
+		g := e.node
+		if g.pos < len(ix.nodes) && ix.nodes[g.pos].group == g {
+			return g.pos + 1, g.end, true
+		}
+		return 0, 0, true
+	}
+	return 0, 0, false
+}
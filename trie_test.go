@@ -0,0 +1,262 @@
+package avpindexer
+
+import (
+	"fmt"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+)
+
+// the index NewAvpIndexer used to build before the trie: every AVP listed under its id, matched one by one.  Kept
+// here to check the trie against, and to benchmark it.
+type flatIndex struct {
+	index map[avpId][]pathElementLeafNode
+	nodes []pathElementLeafNode
+}
+
+func newFlatIndex(d *Diameter) *flatIndex {
+	fi := &flatIndex{index: make(map[avpId][]pathElementLeafNode, 1)}
+	for _, avp := range d.AVPs {
+		fi.add(nil, avp)
+	}
+	return fi
+}
+
+func (fi *flatIndex) add(parent *pathElement, avp *AVP) {
+	pe := pathElement{avpId: avpId{vendorId: avp.VendorCode, attrId: avp.AttributeCode}, parent: parent}
+	p := pathElementLeafNode{pathElement: pe, avp: avp, group: &pe}
+	fi.index[pe.avpId] = append(fi.index[pe.avpId], p)
+	fi.nodes = append(fi.nodes, p)
+	for _, sub := range avp.Grouped {
+		fi.add(&pe, sub)
+	}
+}
+
+func (fi *flatIndex) visit(path *pathElement, f func(pe pathElementLeafNode)) {
+	cands := fi.index[path.avpId]
+	if path.avpId.hasWildcard() {
+		cands = fi.nodes
+	}
+	for _, pe := range cands {
+		if pe.matches(path) {
+			f(pe)
+		}
+	}
+}
+
+// a CCR-U with n MSCCs, like those from busy PCEFs
+func largeMessage(t testing.TB, n int) *Diameter {
+	m := NewMessage(272, 4, true).
+		AVPByName("Session-Id", "pcef.example.com;1;2").
+		AVPByName("Origin-Host", "pcef.example.com").
+		AVPByName("Origin-Realm", "example.com").
+		AVPByName("CC-Request-Type", "UPDATE_REQUEST").
+		AVPByName("CC-Request-Number", uint32(7))
+	for i := 0; i < n; i++ {
+		m.GroupByName("Multiple-Services-Credit-Control", func(g *GroupBuilder) {
+			g.AVPByName("Rating-Group", uint32(100+i)).
+				AVPByName("Service-Identifier", uint32(i)).
+				GroupByName("Used-Service-Unit", func(g *GroupBuilder) {
+					g.AVPByName("CC-Input-Octets", uint64(i*1000)).
+						AVPByName("CC-Output-Octets", uint64(i*2000)).
+						AVPByName("CC-Time", uint32(60))
+				}).
+				GroupByName("Requested-Service-Unit", func(g *GroupBuilder) {})
+		})
+	}
+	m.GroupByName("Service-Information", func(g *GroupBuilder) {
+		g.GroupByName("PS-Information", func(g *GroupBuilder) {
+			g.AVP(10415, 2064, "Sprint").AVP(10415, 1228, net.ParseIP("78.147.12.161"))
+		})
+	})
+	dia, err := m.Diameter()
+	a.NilError(t, err)
+	return dia
+}
+
+var trieTestPaths = []string{
+	"0/485",
+	"0/432",
+	"10415/2040/0/432",
+	"10415/874/10415/2040/0/432",
+	"/10415/873/**/0/432",
+	"**/0/432",
+	"*/432",
+	"0/*",
+	"10415/874/*/*",
+	"0/456/0/446/0/421",
+	"/0/456/0/432",
+	"/0/432",
+	"0/446/*/*",
+	"1/2/3/4",
+}
+
+func TestTrieMatchesFlatIndex(t *testing.T) {
+	for name, dia := range map[string]*Diameter{"small": d, "large": largeMessage(t, 60)} {
+		fi := newFlatIndex(dia)
+		ai := NewAvpIndexer(dia)
+		for _, s := range trieTestPaths {
+			path := MustParsePath(s).leaf
+			var want []*AVP
+			fi.visit(path, func(pe pathElementLeafNode) { want = append(want, pe.avp) })
+			var got []*AVP
+			ai.visitIntfcp(path, func(avp *AVP) { got = append(got, avp) })
+			a.Equal(t, len(got), len(want), "%s %s", name, s)
+			for i := range want {
+				a.Equal(t, got[i], want[i], "%s %s", name, s)
+			}
+			if len(want) > 0 {
+				a.Equal(t, ai.lastAvp(path), want[len(want)-1], "%s %s", name, s)
+				a.Equal(t, ai.nthAvp(path, len(want)/2), want[len(want)/2], "%s %s", name, s)
+			}
+		}
+	}
+}
+
+func TestTrieGroupInstances(t *testing.T) {
+	dia := largeMessage(t, 60)
+	ai := NewAvpIndexer(dia)
+	var rgs []uint32
+	ai.EachGroup(0, 456, func(mscc Indexer) {
+		rgs = append(rgs, mscc.GetUint32(0, 432))
+		a.Equal(t, mscc.Count(0, 432), 1)
+		a.Equal(t, mscc.(avpIndexerWithPath).Descendants().Count(Wildcard, Wildcard), 7)
+	})
+	a.Equal(t, len(rgs), 60)
+	a.Equal(t, rgs[59], uint32(159))
+
+	// instances of another message match nothing
+	other := NewAvpIndexer(d)
+	sdc := other.Groups(10415, 2040)[0].(avpIndexerWithPath)
+	a.Equal(t, ai.QueryPath(Path{leaf: leafPath(sdc.parent, 0, 432)}).Count(), 0)
+
+	// the zero value is empty
+	a.Equal(t, AvpIndexer{}.Count(0, 432), 0)
+	a.Equal(t, AvpIndexer{}.BuildIndex().GetUint32(0, 432), uint32(0))
+}
+
+// typical CCRs and ACRs hold some 30 to 150 AVPs
+var benchMessageSizes = []int{4, 8, 14, 20, 60}
+
+func benchMessages(b *testing.B) map[string]*Diameter {
+	msgs := map[string]*Diameter{"small": d}
+	for _, n := range benchMessageSizes {
+		msgs[fmt.Sprint(n)] = largeMessage(b, n)
+	}
+	return msgs
+}
+
+func BenchmarkIndexBuild(b *testing.B) {
+	for name, dia := range benchMessages(b) {
+		dia := dia
+		name = fmt.Sprintf("%s(%d)", name, numAvps(dia.AVPs))
+		b.Run(name+"/flat", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				newFlatIndex(dia)
+			}
+		})
+		b.Run(name+"/lazy", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewAvpIndexer(dia)
+			}
+		})
+		b.Run(name+"/trie", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewAvpIndexer(dia).ix.pathTrie()
+			}
+		})
+	}
+}
+
+// flat is the id map the trie replaced, linear and trie the two ways scan looks up, index what it picks for the
+// message's size
+func BenchmarkIndexLookup(b *testing.B) {
+	paths := []string{"0/485", "0/456/0/432", "0/456/0/446/0/421", "**/0/432", "*/421"}
+	for name, dia := range benchMessages(b) {
+		fi := newFlatIndex(dia)
+		ai := NewAvpIndexer(dia)
+		ix := ai.ix
+		ix.pathTrie()
+		name = fmt.Sprintf("%s(%d)", name, numAvps(dia.AVPs))
+		for _, s := range paths {
+			path := MustParsePath(s).leaf
+			count := func(*pathElementLeafNode) bool { return true }
+			b.Run(fmt.Sprintf("%s/flat/%s", name, s), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					fi.visit(path, func(pathElementLeafNode) {})
+				}
+			})
+			b.Run(fmt.Sprintf("%s/linear/%s", name, s), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					ix.scanRange(0, len(ix.nodes), path, false, count)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/trie/%s", name, s), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					ix.scanTrie(path, false, count)
+				}
+			})
+			b.Run(fmt.Sprintf("%s/index/%s", name, s), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					ai.countAvps(path)
+				}
+			})
+		}
+
+		// one lookup per MSCC instance
+		b.Run(name+"/flat/EachGroup", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fi.visit(leafPath(nil, 0, 456), func(mscc pathElementLeafNode) {
+					fi.visit(leafPath(&pathElement{kind: kindNode, node: mscc.group}, 0, 432),
+						func(pathElementLeafNode) {})
+				})
+			}
+		})
+		b.Run(name+"/index/EachGroup", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ai.EachGroup(0, 456, func(mscc Indexer) { mscc.Count(0, 432) })
+			}
+		})
+	}
+}
+
+// indexing a message and then a dozen lookups in it, the common case
+func BenchmarkIndexMessage(b *testing.B) {
+	var paths []*pathElement
+	for _, s := range []string{"0/263", "0/264", "0/296", "0/416", "0/415", "0/485", "0/456/0/432", "**/0/432",
+		"0/456/0/446/0/421", "0/456/0/413/0/421", "10415/873/10415/874/10415/1228", "*/421"} {
+		paths = append(paths, MustParsePath(s).leaf)
+	}
+	for name, dia := range benchMessages(b) {
+		name = fmt.Sprintf("%s(%d)", name, numAvps(dia.AVPs))
+		dia := dia
+		b.Run(name+"/flat", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fi := newFlatIndex(dia)
+				for _, p := range paths {
+					fi.visit(p, func(pathElementLeafNode) {})
+				}
+			}
+		})
+		b.Run(name+"/index", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ai := NewAvpIndexer(dia)
+				for _, p := range paths {
+					ai.countAvps(p)
+				}
+			}
+		})
+		b.Run(name+"/trie", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ix := NewAvpIndexer(dia).ix
+				ix.pathTrie()
+				for _, p := range paths {
+					ix.scanTrie(p, false, func(*pathElementLeafNode) bool { return true })
+				}
+			}
+		})
+	}
+}
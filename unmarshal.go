@@ -0,0 +1,233 @@
+package avpindexer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Struct unmarshalling, filling tagged fields of a struct from a message in one call:
+//
+//	type usage struct {
+//		RatingGroup uint32     `avp:"Rating-Group"`
+//		Octets      *uint64    `avp:"0/364"`
+//		FirstUsage  time.Time  `avp:"10415/2043"`
+//	}
+//	type acr struct {
+//		RecordType  Enum       `avp:"Accounting-Record-Type"`
+//		ChargingId  uint32     `avp:"Service-Information/PS-Information/3GPP-Charging-Id"`
+//		Usage       []usage    `avp:"Service-Information/PS-Information/Service-Data-Container"`
+//		SGSN        []net.IP   `avp:"10415/1228"`
+//	}
+//	var r acr
+//	err := Unmarshal(dia, &r)
+//
+// A tag holds a path, in ParsePath form (ids) or ParseNamePath form (names, resolved with the indexer's
+// dictionary), relative to the enclosing group.  The field type decides how matching AVPs are read:
+//
+//	T (a registered type, see RegisterType)    value of the first match, zero if there is none
+//	*T                                         same, nil if there is none
+//	[]T                                        values of all matches, in message order
+//	struct                                     the first matching grouped AVP, its fields filled in turn
+//	*struct                                    same, nil if there is none
+//	[]struct, []*struct                        one per matching grouped AVP
+//
+// Named types over uint32, string etc (such as avpgen's enum types) are read as their underlying type.  Fields
+// without an avp tag, or tagged "-", are left alone; unexported tagged fields are an error, and so are tag options
+// other than Marshal's omitempty.  An AVP that can't be read as its field's type is an error.
+
+// Fill the tagged fields of the struct v points to from message d, see above.
+func Unmarshal(d *Diameter, v interface{}) error {
+	return UnmarshalIndexer(NewAvpIndexer(d), v)
+}
+
+// Fill the tagged fields of the struct v points to, with paths relative to ix.
+func UnmarshalIndexer(ix Indexer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal: need a non-nil pointer to a struct, got %T", v)
+	}
+	ai, _ := ix.scope()
+	if err := unmarshalStruct(ix, ai.dictionary(), rv.Elem(), rv.Elem().Type().Name()); err != nil {
+		return fmt.Errorf("Unmarshal: %w", err)
+	}
+	return nil
+}
+
+// a tagged field of a struct, see tagFields
+type tagField struct {
+	index int
+	name  string // qualified by the struct's name, for errors
+	path  Path
+}
+
+// the tagged fields of struct type st, their paths resolved with d
+func tagFields(d *dictionary.Dictionary, st reflect.Type, name string) ([]tagField, error) {
+	var fields []tagField
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		tag, ok := f.Tag.Lookup("avp")
+		if !ok || tag == "-" {
+			continue
+		}
+		fname := name + "." + f.Name
+		if !f.IsExported() {
+			return nil, fmt.Errorf("field %s is not exported", fname)
+		}
+		path, _, err := splitTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fname, err)
+		}
+		p, err := parseTagPath(d, path)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fname, err)
+		}
+		fields = append(fields, tagField{index: i, name: fname, path: p})
+	}
+	return fields, nil
+}
+
+func unmarshalStruct(ix Indexer, d *dictionary.Dictionary, sv reflect.Value, name string) error {
+	fields, err := tagFields(d, sv.Type(), name)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := unmarshalField(ix.QueryPath(f.path), d, sv.Field(f.index), f.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalField(q avpQuery, d *dictionary.Dictionary, fv reflect.Value, fname string) error {
+	ft := fv.Type()
+	switch {
+	case scalarDecoder(ft) != nil:
+		avp := q.First()
+		if avp == nil {
+			return nil
+		}
+		v, err := decodeScalar(q, d, ft, avp)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fname, err)
+		}
+		fv.Set(v)
+
+	case ft.Kind() == reflect.Ptr && scalarDecoder(ft.Elem()) != nil:
+		avp := q.First()
+		if avp == nil {
+			return nil
+		}
+		v, err := decodeScalar(q, d, ft.Elem(), avp)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fname, err)
+		}
+		pv := reflect.New(ft.Elem())
+		pv.Elem().Set(v)
+		fv.Set(pv)
+
+	case ft.Kind() == reflect.Slice && scalarDecoder(ft.Elem()) != nil:
+		var err error
+		sl := reflect.MakeSlice(ft, 0, 0)
+		q.VisitAvp(func(avp *AVP) {
+			if err != nil {
+				return
+			}
+			var v reflect.Value
+			if v, err = decodeScalar(q, d, ft.Elem(), avp); err == nil {
+				sl = reflect.Append(sl, v)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fname, err)
+		}
+		if sl.Len() > 0 {
+			fv.Set(sl)
+		}
+
+	case isGroupStruct(ft):
+		if groups := q.Groups(); len(groups) > 0 {
+			return unmarshalStruct(groups[0], d, fv, fname)
+		}
+
+	case ft.Kind() == reflect.Ptr && isGroupStruct(ft.Elem()):
+		if groups := q.Groups(); len(groups) > 0 {
+			pv := reflect.New(ft.Elem())
+			if err := unmarshalStruct(groups[0], d, pv.Elem(), fname); err != nil {
+				return err
+			}
+			fv.Set(pv)
+		}
+
+	case ft.Kind() == reflect.Slice && (isGroupStruct(ft.Elem()) ||
+		ft.Elem().Kind() == reflect.Ptr && isGroupStruct(ft.Elem().Elem())):
+		groups := q.Groups()
+		if len(groups) == 0 {
+			return nil
+		}
+		et := ft.Elem()
+		sl := reflect.MakeSlice(ft, len(groups), len(groups))
+		for i, g := range groups {
+			ev := sl.Index(i)
+			if et.Kind() == reflect.Ptr {
+				ev.Set(reflect.New(et.Elem()))
+				ev = ev.Elem()
+			}
+			if err := unmarshalStruct(g, d, ev, fmt.Sprintf("%s[%d]", fname, i)); err != nil {
+				return err
+			}
+		}
+		fv.Set(sl)
+
+	default:
+		return fmt.Errorf("field %s: %w %s", fname, ErrUnregisteredType, ft)
+	}
+	return nil
+}
+
+// decode avp as a t (see scalarDecoder), filling in the query path on errors
+func decodeScalar(q avpQuery, d *dictionary.Dictionary, t reflect.Type, avp *AVP) (reflect.Value, error) {
+	v, err := scalarDecoder(t)(d, avp)
+	if err != nil {
+		var ae *AvpError
+		if errors.As(err, &ae) && ae.Path == "" {
+			ae.Path = Path{leaf: q.path}.String()
+		}
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(v), nil
+}
+
+// structs that aren't read as one value (time.Time is) stand for grouped AVPs
+func isGroupStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && scalarDecoder(t) == nil
+}
+
+// path and options of a struct tag, `avp:"path,omitempty"`; omitempty only matters to Marshal, other options are an
+// error
+func splitTag(tag string) (path string, omitEmpty bool, err error) {
+	opts := strings.Split(tag, ",")
+	for _, o := range opts[1:] {
+		switch o = strings.TrimSpace(o); o {
+		case "omitempty":
+			omitEmpty = true
+		case "":
+		default:
+			return "", false, fmt.Errorf("unknown tag option %q", o)
+		}
+	}
+	return opts[0], omitEmpty, nil
+}
+
+// path from a struct tag: ids as for ParsePath, or names as for ParseNamePath
+func parseTagPath(d *dictionary.Dictionary, tag string) (Path, error) {
+	p, err := ParsePath(tag)
+	if err == nil || strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+		return p, err
+	}
+	return ParseNamePath(d, tag)
+}
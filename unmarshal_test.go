@@ -0,0 +1,112 @@
+package avpindexer
+
+import (
+	"errors"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type testContainer struct {
+	RatingGroup  uint32    `avp:"Rating-Group"`
+	InputOctets  uint64    `avp:"0/363"`
+	OutputOctets *uint64   `avp:"Accounting-Output-Octets"`
+	TimeUsage    uint32    `avp:"10415/2045"`
+	FirstUsage   time.Time `avp:"Time-First-Usage"`
+	Missing      *uint32   `avp:"0/999"`
+	Ignored      string    `avp:"-"`
+	untagged     int
+}
+
+type testSubscription struct {
+	Type testSubscriptionType `avp:"Subscription-Id-Type"`
+	Data string               `avp:"Subscription-Id-Data"`
+}
+
+type testSubscriptionType uint32
+
+type testACR struct {
+	SessionId    string             `avp:"Session-Id"`
+	RecordType   Enum               `avp:"Accounting-Record-Type"`
+	RecordNumber *uint32            `avp:"0/485"`
+	EventTime    time.Time          `avp:"0/55"`
+	Subscription []testSubscription `avp:"Service-Information/Subscription-Id"`
+	PS           *struct {
+		ChargingId uint32           `avp:"3GPP-Charging-Id"`
+		NodeId     []byte           `avp:"10415/2064"`
+		SGSN       []net.IP         `avp:"SGSN-Address"`
+		Containers []*testContainer `avp:"Service-Data-Container"`
+	} `avp:"Service-Information/PS-Information"`
+	First   testContainer  `avp:"**/Service-Data-Container"`
+	NoGroup *testContainer `avp:"0/998"`
+	None    []uint32       `avp:"0/997"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	var r testACR
+	a.NilError(t, Unmarshal(d, &r))
+	a.Equal(t, r.SessionId, ai.GetUTF8String(0, 263))
+	a.Equal(t, r.RecordType, Enum{Value: 4, Name: "STOP_RECORD"})
+	a.Equal(t, *r.RecordNumber, uint32(1))
+	a.Equal(t, r.EventTime, ai.GetTime(0, 55))
+	a.DeepEqual(t, r.Subscription, []testSubscription{{Type: 0, Data: "41576568877"}})
+
+	a.Assert(t, r.PS != nil)
+	a.Equal(t, r.PS.ChargingId, uint32(0x5e9ed913))
+	a.Equal(t, string(r.PS.NodeId), "Sprint")
+	a.Equal(t, len(r.PS.SGSN), 1)
+	a.Equal(t, r.PS.SGSN[0].String(), "78.147.12.161")
+	a.Equal(t, len(r.PS.Containers), 2)
+	c := r.PS.Containers[1]
+	a.Equal(t, c.RatingGroup, uint32(4001))
+	a.Equal(t, c.InputOctets, uint64(13492))
+	a.Equal(t, *c.OutputOctets, uint64(26694))
+	a.Equal(t, c.TimeUsage, uint32(600))
+	a.Equal(t, c.FirstUsage, ai.Query("**/10415/2040").Groups()[1].GetTime(10415, 2043))
+	a.Assert(t, c.Missing == nil)
+
+	a.Equal(t, r.First.RatingGroup, uint32(0))
+	a.Equal(t, r.First.TimeUsage, uint32(241))
+	a.Assert(t, r.NoGroup == nil)
+	a.Assert(t, r.None == nil)
+
+	// relative to a scoped indexer
+	var sc testContainer
+	a.NilError(t, UnmarshalIndexer(ai.Query("**/10415/2040").Groups()[1], &sc))
+	a.Equal(t, sc.RatingGroup, uint32(4001))
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var r testACR
+	a.ErrorContains(t, Unmarshal(d, r), "pointer to a struct")
+
+	var bad struct {
+		Session uint64 `avp:"Session-Id"`
+	}
+	err := Unmarshal(d, &bad)
+	a.Assert(t, errors.Is(err, ErrAvpDecode) || errors.Is(err, ErrAvpTypeMismatch))
+	a.ErrorContains(t, err, "field .Session")
+	a.ErrorContains(t, err, "0/263")
+
+	var unknown struct {
+		X uint32 `avp:"No-Such-AVP"`
+	}
+	a.ErrorContains(t, Unmarshal(d, &unknown), "field .X")
+
+	var unsupported struct {
+		X map[string]int `avp:"0/263"`
+	}
+	a.Assert(t, errors.Is(Unmarshal(d, &unsupported), ErrUnregisteredType))
+
+	var nested struct {
+		Containers []struct {
+			RatingGroup uint32 `avp:"Rating-Group"`
+			Usage       Enum   `avp:"10415/2045"`
+		} `avp:"**/Service-Data-Container"`
+	}
+	err = Unmarshal(d, &nested)
+	a.ErrorContains(t, err, "field .Containers[0].Usage")
+}
@@ -0,0 +1,210 @@
+package avpindexer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/rjm2718/avpindexer/dictionary"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Dictionary driven decoding: the Go type of a value follows the AVP's declared type in the dictionary, so generic
+// code (printers, exporters, filters) needn't know that 10415/2045 is an Unsigned32.
+//
+//	Unsigned32, Enumerated                          uint32
+//	Unsigned64, Integer32, Integer64                uint64, int32, int64
+//	Float32, Float64                                float32, float64
+//	Time                                            time.Time
+//	Address                                         net.IP
+//	UTF8String, DiameterIdentity, DiameterURI,
+//	IPFilterRule, QoSFilterRule                     string
+//	OctetString                                     []byte
+//	Grouped                                         map[string]interface{}
+//
+// Grouped values map each sub-AVP's name (vendorId/attrId if the dictionary doesn't know it) to its value; an AVP
+// that occurs more than once in the group maps to a []interface{} of its values in message order.  AVPs missing
+// from the dictionary are decoded by their AVP decoder, or returned as []byte.
+
+// seconds between the NTP epoch (1900) used by Diameter Time and the unix epoch
+const ntpEpochOffset = 2208988800
+
+// retrieve first matching value with given id, typed by its dictionary definition; nil if there is no such AVP or
+// it can't be decoded
+func (ai AvpIndexer) GetValue(vendorId, attrId uint32) interface{} {
+	v, _ := ai.GetValueE(vendorId, attrId)
+	return v
+}
+
+// retrieve first matching value with given id, typed by its dictionary definition; nil if there is no such AVP or
+// it can't be decoded
+func (aip avpIndexerWithPath) GetValue(vendorId, attrId uint32) interface{} {
+	v, _ := aip.GetValueE(vendorId, attrId)
+	return v
+}
+
+// retrieve first matching value, typed by its dictionary definition; nil if there is no such AVP or it can't be
+// decoded
+func (q avpQuery) GetValue() interface{} {
+	v, _ := q.GetValueE()
+	return v
+}
+
+// retrieve first matching value with given id, typed by its dictionary definition, or an *AvpError and nil
+func (ai AvpIndexer) GetValueE(vendorId, attrId uint32) (interface{}, error) {
+	return ai.valueAt(leafPath(nil, vendorId, attrId))
+}
+
+// retrieve first matching value with given id, typed by its dictionary definition, or an *AvpError and nil
+func (aip avpIndexerWithPath) GetValueE(vendorId, attrId uint32) (interface{}, error) {
+	return aip.valueAt(leafPath(aip.parent, vendorId, attrId))
+}
+
+// retrieve first matching value, typed by its dictionary definition, or an *AvpError (or the path parse error) and
+// nil
+func (q avpQuery) GetValueE() (interface{}, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+	if q.path == nil {
+		return nil, &AvpError{Kind: ErrAvpNotFound}
+	}
+	return q.ai.valueAt(q.path)
+}
+
+func (ai AvpIndexer) valueAt(path *pathElement) (interface{}, error) {
+	avp := ai.firstAvp(path)
+	if avp == nil {
+		return nil, &AvpError{Kind: ErrAvpNotFound, Path: Path{leaf: path}.String()}
+	}
+	v, err := DecodeValue(ai.dictionary(), avp)
+	if err != nil {
+		var ae *AvpError
+		if errors.As(err, &ae) && ae.Path == "" {
+			ae.Path = Path{leaf: path}.String()
+		}
+	}
+	return v, err
+}
+
+// Decode avp by its definition in d (dictionary.Default() if nil), see GetValue for the resulting Go types.
+func DecodeValue(d *dictionary.Dictionary, avp *AVP) (interface{}, error) {
+	if d == nil {
+		d = dictionary.Default()
+	}
+	def := d.AVP(avp.VendorCode, avp.AttributeCode)
+	if def == nil {
+		if len(avp.Grouped) > 0 {
+			return decodeGrouped(d, avp)
+		}
+		if v := decodedValue(avp); v != nil {
+			return v, nil
+		}
+		return avp.Data, nil
+	}
+	if def.Type == dictionary.Grouped {
+		return decodeGrouped(d, avp)
+	}
+	v, err := decodeData(def.Type, avp.Data)
+	if err != nil {
+		return nil, &AvpError{Kind: ErrAvpDecode, Avp: avp, Wanted: def.Type.String(), Err: err}
+	}
+	return v, nil
+}
+
+func decodeGrouped(d *dictionary.Dictionary, avp *AVP) (interface{}, error) {
+	m := make(map[string]interface{}, len(avp.Grouped))
+	for _, sub := range avp.Grouped {
+		v, err := DecodeValue(d, sub)
+		if err != nil {
+			return nil, err
+		}
+		// decoded values are never []interface{}, so one here collects a repeated AVP
+		k := avpName(d, sub)
+		switch prev := m[k].(type) {
+		case nil:
+			m[k] = v
+		case []interface{}:
+			m[k] = append(prev, v)
+		default:
+			m[k] = []interface{}{prev, v}
+		}
+	}
+	return m, nil
+}
+
+// dictionary name of avp, or vendorId/attrId
+func avpName(d *dictionary.Dictionary, avp *AVP) string {
+	if def := d.AVP(avp.VendorCode, avp.AttributeCode); def != nil {
+		return def.Name
+	}
+	return strconv.FormatUint(uint64(avp.VendorCode), 10) + "/" + strconv.FormatUint(uint64(avp.AttributeCode), 10)
+}
+
+// decode AVP data of a non grouped type
+func decodeData(t dictionary.Type, b []byte) (interface{}, error) {
+	fixed := func(n int) error {
+		if len(b) != n {
+			return fmt.Errorf("data length %d, expected %d", len(b), n)
+		}
+		return nil
+	}
+	switch t {
+	case dictionary.Unsigned32, dictionary.Enumerated:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint32(b), nil
+	case dictionary.Unsigned64:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint64(b), nil
+	case dictionary.Integer32:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	case dictionary.Integer64:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case dictionary.Float32:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), nil
+	case dictionary.Float64:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case dictionary.Time:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		// values with the top bit clear are past the 2036 NTP rollover, RFC 5905 section 6
+		secs := int64(binary.BigEndian.Uint32(b))
+		if secs < 1<<31 {
+			secs += 1 << 32
+		}
+		return time.Unix(secs-ntpEpochOffset, 0).UTC(), nil
+	case dictionary.Address:
+		// 2 byte address family, RFC 6733 section 4.3.1.  Some equipment sends bogus families, so the address is
+		// told by its length.
+		switch len(b) {
+		case 2 + net.IPv4len, 2 + net.IPv6len:
+			return net.IP(append([]byte(nil), b[2:]...)), nil
+		}
+		return nil, fmt.Errorf("data length %d, not an IPv4 or IPv6 address", len(b))
+	case dictionary.UTF8String, dictionary.DiameterIdentity, dictionary.DiameterURI, dictionary.IPFilterRule,
+		dictionary.QoSFilterRule:
+		return string(b), nil
+	case dictionary.OctetString:
+		return b, nil
+	}
+	return nil, fmt.Errorf("no decoding for type %s", t)
+}
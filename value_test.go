@@ -0,0 +1,91 @@
+package avpindexer
+
+import (
+	"errors"
+	"github.com/rjm2718/avpindexer/dictionary"
+	a "gotest.tools/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGetValue(t *testing.T) {
+	ai := NewAvpIndexer(d)
+
+	a.Equal(t, ai.GetValue(0, 485), uint32(1))
+	a.Equal(t, ai.GetValue(0, 480), uint32(4))
+	a.Equal(t, ai.GetValue(10415, 2064), "Sprint")
+	a.Equal(t, ai.GetValue(0, 264), ai.GetUTF8String(0, 264))
+	a.Equal(t, ai.GetValue(0, 55).(time.Time), ai.GetTime(0, 55))
+	a.Equal(t, ai.FromGroup(10415, 874).GetValue(10415, 1228).(net.IP).String(), "78.147.12.161")
+	_, isBytes := ai.GetValue(10415, 21).([]byte)
+	a.Assert(t, isBytes)
+	a.Assert(t, ai.GetValue(0, 999) == nil)
+
+	a.DeepEqual(t, ai.GetValue(0, 443), map[string]interface{}{
+		"Subscription-Id-Type": uint32(0),
+		"Subscription-Id-Data": "41576568877",
+	})
+	ps := ai.Query("10415/873/10415/874").GetValue().(map[string]interface{})
+	a.Equal(t, ps["3GPP-Charging-Id"], uint32(0x5e9ed913))
+	sdcs := ps["Service-Data-Container"].([]interface{})
+	a.Equal(t, len(sdcs), 2)
+	a.Equal(t, sdcs[1].(map[string]interface{})["Rating-Group"], uint32(4001))
+	a.Equal(t, sdcs[1].(map[string]interface{})["Accounting-Output-Octets"], uint64(26694))
+	_, isIP := sdcs[0].(map[string]interface{})["SGSN-Address"].(net.IP)
+	a.Assert(t, isIP)
+}
+
+func TestGetValueDictionary(t *testing.T) {
+	// AVPs missing from the dictionary fall back to their AVP decoder, and are keyed by id in groups
+	ai := NewAvpIndexer(d).WithDictionary(dictionary.New())
+	a.Equal(t, ai.GetValue(0, 485), uint32(1))
+	sub := ai.GetValue(0, 443).(map[string]interface{})
+	a.Equal(t, sub["0/444"], "41576568877")
+
+	// a definition that doesn't fit the data is a decode error
+	dict := dictionary.New()
+	dict.AddAVP(&dictionary.AVP{Name: "Accounting-Record-Number", Code: 485, Type: dictionary.Unsigned64})
+	_, err := NewAvpIndexer(d).WithDictionary(dict).GetValueE(0, 485)
+	a.Assert(t, errors.Is(err, ErrAvpDecode))
+	a.ErrorContains(t, err, "0/485")
+
+	_, err = NewAvpIndexer(d).GetValueE(0, 999)
+	a.Assert(t, errors.Is(err, ErrAvpNotFound))
+}
+
+func TestDecodeValueLazy(t *testing.T) {
+	// AVPs missing from the dictionary fall back to their decoder; one that failed to decode gives its data
+	b, err := appendMessage(nil, 1, 0, 272, 4, 1, 2, testAvp(t, 432, 0x40, 0, []byte{0, 0, 1}))
+	a.NilError(t, err)
+	for _, decode := range []func(*dictionary.Dictionary, []byte) (*Diameter, error){Decode, DecodeLazy} {
+		dia, err := decode(nil, b)
+		a.NilError(t, err)
+		v, err := DecodeValue(dictionary.New(), dia.AVPs[0])
+		a.NilError(t, err)
+		a.DeepEqual(t, v, []byte{0, 0, 1})
+	}
+}
+
+func TestDecodeData(t *testing.T) {
+	v, err := DecodeValue(nil, &AVP{AttributeCode: 55, Data: []byte{0xe1, 0x47, 0x2b, 0x23}})
+	a.NilError(t, err)
+	a.Equal(t, v, time.Date(2019, 10, 8, 15, 34, 59, 0, time.UTC))
+	// past the NTP rollover in 2036
+	v, err = decodeData(dictionary.Time, []byte{0, 0, 0, 1})
+	a.NilError(t, err)
+	a.Equal(t, v, time.Date(2036, 2, 7, 6, 28, 17, 0, time.UTC))
+
+	v, err = decodeData(dictionary.Address, []byte{0, 2, 0x20, 1, 0xd, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	a.NilError(t, err)
+	a.Equal(t, v.(net.IP).String(), "2001:db8::1")
+	_, err = decodeData(dictionary.Address, []byte{0, 1, 10, 0, 0})
+	a.ErrorContains(t, err, "data length 5, not an IPv4 or IPv6 address")
+
+	v, err = decodeData(dictionary.Integer32, []byte{0xff, 0xff, 0xff, 0xfe})
+	a.NilError(t, err)
+	a.Equal(t, v, int32(-2))
+	v, err = decodeData(dictionary.Float64, []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0})
+	a.NilError(t, err)
+	a.Equal(t, v, 1.5)
+}